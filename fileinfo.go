@@ -1,7 +1,10 @@
 package librfm
 
 import (
+	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,12 +28,62 @@ type Fileinfo struct {
 	// PrintTime in seconds for a job file
 	PrintTime uint64
 	// Filament contains an array of used filaments in mm
-	Filament []float64
+	Filament FilamentLengths
 	// GeneratedBy returns the string which application created the job file
 	GeneratedBy string
 }
 
+// UnmarshalJSON decodes a Fileinfo accepting Err given either as JSON number or string
+func (f *Fileinfo) UnmarshalJSON(b []byte) error {
+	type fileinfo Fileinfo
+	v := struct {
+		*fileinfo
+		Err errorCode
+	}{fileinfo: (*fileinfo)(f)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	f.Err = uint64(v.Err)
+	return nil
+}
+
 // LastModified returns the last modification time of this file
 func (f *Fileinfo) LastModified() time.Time {
 	return f.Timestamp.Time
 }
+
+// FilamentLengths holds the filament usage per extruder in mm
+type FilamentLengths []float64
+
+// UnmarshalJSON decodes filament usage given as JSON array, as single number or as
+// string of comma separated numbers like some slicers write for single extruder jobs
+func (f *FilamentLengths) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	switch {
+	case s == "null":
+		*f = nil
+		return nil
+	case strings.HasPrefix(s, "["):
+		var l []float64
+		if err := json.Unmarshal(b, &l); err != nil {
+			return err
+		}
+		*f = l
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	l := make(FilamentLengths, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		l = append(l, n)
+	}
+	*f = l
+	return nil
+}
@@ -0,0 +1,39 @@
+package librfm
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestFilamentLengthsShapes(t *testing.T) {
+	tests := []struct {
+		filament string
+		want     FilamentLengths
+	}{
+		{`[1854.3]`, FilamentLengths{1854.3}},
+		{`[1854.3, 12.5]`, FilamentLengths{1854.3, 12.5}},
+		{`[]`, FilamentLengths{}},
+		{`1854.3`, FilamentLengths{1854.3}},
+		{`0`, FilamentLengths{0}},
+		{`"1854.3"`, FilamentLengths{1854.3}},
+		{`"1854.3, 12.5"`, FilamentLengths{1854.3, 12.5}},
+		{`""`, FilamentLengths{}},
+		{`null`, nil},
+	}
+	for _, tt := range tests {
+		var f Fileinfo
+		if err := json.Unmarshal([]byte(`{"err":0,"filament":`+tt.filament+`}`), &f); err != nil {
+			t.Errorf("filament %s: %v", tt.filament, err)
+			continue
+		}
+		if fmt.Sprint(f.Filament) != fmt.Sprint(tt.want) || (f.Filament == nil) != (tt.want == nil) {
+			t.Errorf("filament %s = %#v, want %#v", tt.filament, f.Filament, tt.want)
+		}
+	}
+
+	var f Fileinfo
+	if err := json.Unmarshal([]byte(`{"filament":"a lot"}`), &f); err == nil {
+		t.Errorf("invalid filament decoded as %v", f.Filament)
+	}
+}
@@ -1,8 +1,8 @@
 package librfm
 
 import (
+	"encoding/json"
 	"errors"
-	"fmt"
 	"sync"
 	"time"
 )
@@ -61,28 +61,45 @@ type Filelist struct {
 	index   map[string]bool
 }
 
-// Contains checks for a path to exist in this filelist
+// UnmarshalJSON decodes a Filelist accepting Err given either as JSON number or string
+func (f *Filelist) UnmarshalJSON(b []byte) error {
+	type filelist Filelist
+	v := struct {
+		*filelist
+		Err errorCode
+	}{filelist: (*filelist)(f)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	f.Err = uint64(v.Err)
+	return nil
+}
+
+// Contains checks for a path to exist in this filelist. Directories are only found
+// if their own listing is part of the Filelist, i.e. its Dir and all Subdirs of a
+// recursive listing. A trailing slash on directory paths is ignored.
 func (f *Filelist) Contains(path string) bool {
 	f.once.Do(f.buildIndex)
-	return f.index[path]
+	return f.index[cleanPath(path)]
 }
 
 func (f *Filelist) buildIndex() {
 	f.index = make(map[string]bool)
 
-	// Init index of subdirs
-	for _, subdir := range f.Subdirs {
-		subdir.buildIndex()
-		for k, v := range subdir.index {
-			f.index[k] = v
-		}
-		f.index[subdir.Dir] = true
-	}
-	for _, file := range f.Files {
-		if file.IsDir() {
-			continue
+	// Traverse the tree iteratively so deeply nested directories do not grow the stack
+	// and subdirs do not have to build (and copy) indexes of their own
+	stack := []*Filelist{f}
+	for len(stack) > 0 {
+		fl := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dir := cleanPath(fl.Dir)
+		f.index[dir] = true
+		for _, file := range fl.Files {
+			if file.IsDir() {
+				continue
+			}
+			f.index[joinPath(dir, file.Name)] = true
 		}
-		f.index[fmt.Sprintf("%s/%s", f.Dir, file.Name)] = true
+		stack = append(stack, fl.Subdirs...)
 	}
-	f.index[f.Dir] = true
 }
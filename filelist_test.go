@@ -0,0 +1,111 @@
+package librfm
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestContainsTrailingSlash(t *testing.T) {
+	root := &Filelist{
+		Dir:   "0:/",
+		Files: []File{{Type: typeDirectory, Name: "gcodes"}, {Type: typeFile, Name: "config.g"}},
+		Subdirs: []*Filelist{{
+			Dir:   "0:/gcodes/",
+			Files: []File{{Type: typeFile, Name: "a.g"}},
+		}},
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"0:/", true},
+		{"0:", true},
+		{"0:/config.g", true},
+		{"0://config.g", true},
+		{"0:/gcodes", true},
+		{"0:/gcodes/", true},
+		{"0:/gcodes/a.g", true},
+		{"0:/gcodes//a.g", true},
+		{"0:/gcodes/a.g/", true},
+		{"0:/missing", false},
+	}
+	for _, tt := range tests {
+		if got := root.Contains(tt.path); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFilelistRecursiveRootHasSingleSlashes(t *testing.T) {
+	var dirs []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		dir := req.URL.Query().Get("dir")
+		dirs = append(dirs, dir)
+		files := `[]`
+		if dir == "0:/" {
+			files = `[{"type":"d","name":"gcodes","size":0,"date":"2024-01-01T00:00:00"}]`
+		}
+		fmt.Fprintf(w, `{"dir":%q,"first":0,"files":%s,"next":0}`, dir, files)
+	})
+	if _, err := r.Filelist("0:/", true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0:/", "0:/gcodes"}
+	if strings.Join(dirs, "|") != strings.Join(want, "|") {
+		t.Errorf("requested dirs %q, want %q", dirs, want)
+	}
+}
+
+// deepTree returns a listing of dirs directories each nested in the previous one
+// and containing files files
+func deepTree(dirs, files int) *Filelist {
+	root := &Filelist{Dir: "0:/gcodes"}
+	fl := root
+	for i := 0; i < dirs; i++ {
+		name := fmt.Sprintf("d%d", i)
+		sub := &Filelist{Dir: fl.Dir + "/" + name}
+		fl.Files = append(fl.Files, File{Type: typeDirectory, Name: name})
+		for j := 0; j < files; j++ {
+			fl.Files = append(fl.Files, File{Type: typeFile, Name: fmt.Sprintf("f%d.g", j)})
+		}
+		fl.Subdirs = []*Filelist{sub}
+		fl = sub
+	}
+	return root
+}
+
+// wideTree returns a listing of dirs directories next to each other each
+// containing files files
+func wideTree(dirs, files int) *Filelist {
+	root := &Filelist{Dir: "0:/gcodes"}
+	for i := 0; i < dirs; i++ {
+		name := fmt.Sprintf("d%d", i)
+		sub := &Filelist{Dir: "0:/gcodes/" + name}
+		for j := 0; j < files; j++ {
+			sub.Files = append(sub.Files, File{Type: typeFile, Name: fmt.Sprintf("f%d.g", j)})
+		}
+		root.Files = append(root.Files, File{Type: typeDirectory, Name: name})
+		root.Subdirs = append(root.Subdirs, sub)
+	}
+	return root
+}
+
+func BenchmarkBuildIndex(b *testing.B) {
+	trees := []struct {
+		name string
+		fl   *Filelist
+	}{
+		{"deep", deepTree(1000, 10)},
+		{"wide", wideTree(100, 100)},
+	}
+	for _, tt := range trees {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tt.fl.buildIndex()
+			}
+		})
+	}
+}
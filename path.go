@@ -0,0 +1,46 @@
+package librfm
+
+import (
+	"path"
+	"strings"
+)
+
+// splitVolume separates a leading volume specifier like "0:" from the rest of p
+func splitVolume(p string) (volume, rest string) {
+	i := strings.IndexByte(p, ':')
+	if i <= 0 {
+		return "", p
+	}
+	for _, c := range p[:i] {
+		if c < '0' || c > '9' {
+			return "", p
+		}
+	}
+	return p[:i+1], p[i+1:]
+}
+
+// cleanPath normalizes p by collapsing duplicate slashes, resolving "." and ".."
+// elements and removing a trailing slash while keeping a leading volume
+// specifier like "0:" and the root of a volume intact
+func cleanPath(p string) string {
+	if p == "" {
+		return p
+	}
+	volume, rest := splitVolume(p)
+	if rest == "" {
+		return volume + "/"
+	}
+	rest = path.Clean(rest)
+	if volume != "" && !strings.HasPrefix(rest, "/") {
+
+		// Anything following a volume is relative to its root
+		rest = path.Clean("/" + rest)
+	}
+	return volume + rest
+}
+
+// joinPath appends name to dir making sure there is exactly one slash in between
+// regardless of whether dir has a trailing slash (e.g. the volume root "0:/")
+func joinPath(dir, name string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
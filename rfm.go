@@ -13,6 +13,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -34,8 +35,28 @@ const (
 	TimeFormat = "2006-01-02T15:04:05"
 )
 
+// errorCode is the numeric error code reported by RRF where 0 means no error.
+// Some firmware or proxy combinations send it as a string so both encodings
+// are accepted when unmarshalling.
+type errorCode uint64
+
+// UnmarshalJSON decodes an error code given either as JSON number or string
+func (e *errorCode) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*e = 0
+		return nil
+	}
+	c, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*e = errorCode(c)
+	return nil
+}
+
 type errorResponse struct {
-	Err uint64
+	Err errorCode
 }
 
 type rrffm struct {
@@ -191,7 +212,7 @@ func (r *rrffm) Fileinfo(path string) (*Fileinfo, error) {
 }
 
 func (r *rrffm) Filelist(dir string, recursive bool) (*Filelist, error) {
-	fl, err := r.getFullFilelist(url.QueryEscape(dir), 0)
+	fl, err := r.getFullFilelist(dir, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -202,7 +223,7 @@ func (r *rrffm) Filelist(dir string, recursive bool) (*Filelist, error) {
 				// Directories come first so once we get here we can skip the remaining
 				break
 			}
-			subfl, err := r.Filelist(fmt.Sprintf("%s/%s", fl.Dir, f.Name), true)
+			subfl, err := r.Filelist(joinPath(fl.Dir, f.Name), true)
 			if err != nil {
 				return nil, err
 			}
@@ -214,7 +235,7 @@ func (r *rrffm) Filelist(dir string, recursive bool) (*Filelist, error) {
 
 func (r *rrffm) getFullFilelist(dir string, first uint64) (*Filelist, error) {
 
-	body, _, err := r.doGetRequest(fmt.Sprintf(filelistURL, r.baseURL, dir, first))
+	body, _, err := r.doGetRequest(fmt.Sprintf(filelistURL, r.baseURL, url.QueryEscape(dir), first))
 	if err != nil {
 		return nil, err
 	}
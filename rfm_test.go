@@ -0,0 +1,108 @@
+package librfm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestManager returns a manager talking to a test server using handler
+func newTestManager(t *testing.T, handler http.HandlerFunc) *rrffm {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &rrffm{httpClient: srv.Client(), baseURL: srv.URL}
+}
+
+func TestFilelistEscapesDirOnce(t *testing.T) {
+	tests := []struct {
+		dir      string
+		rawQuery string
+	}{
+		{"0:/gcodes", "dir=0%3A%2Fgcodes&first=0"},
+		{"0:/gcodes/with space", "dir=0%3A%2Fgcodes%2Fwith+space&first=0"},
+		{"0:/a&b", "dir=0%3A%2Fa%26b&first=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			var got, dir string
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				got = req.URL.RawQuery
+				dir = req.URL.Query().Get("dir")
+				fmt.Fprintf(w, `{"dir":%q,"first":0,"files":[],"next":0}`, dir)
+			})
+			if _, err := r.Filelist(tt.dir, false); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.rawQuery {
+				t.Errorf("raw query = %q, want %q", got, tt.rawQuery)
+			}
+			if dir != tt.dir {
+				t.Errorf("board received dir %q, want %q", dir, tt.dir)
+			}
+		})
+	}
+}
+
+func TestFilelistRecursiveEscapesSubdirs(t *testing.T) {
+	var dirs []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		dir := req.URL.Query().Get("dir")
+		dirs = append(dirs, dir)
+		files := `[]`
+		if !strings.HasSuffix(dir, "sub dir") {
+			files = `[{"type":"d","name":"sub dir","size":0,"date":"2024-01-01T00:00:00"}]`
+		}
+		fmt.Fprintf(w, `{"dir":%q,"first":0,"files":%s,"next":0}`, dir, files)
+	})
+	if _, err := r.Filelist("0:/gcodes", true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0:/gcodes", "0:/gcodes/sub dir"}
+	if strings.Join(dirs, "|") != strings.Join(want, "|") {
+		t.Errorf("requested dirs %q, want %q", dirs, want)
+	}
+}
+
+func TestErrAsNumberOrString(t *testing.T) {
+	tests := []struct {
+		err      string
+		fileinfo error
+		filelist error
+	}{
+		{`0`, nil, nil},
+		{`"0"`, nil, nil},
+		{`1`, ErrFileNotFound, ErrDriveNotMounted},
+		{`"1"`, ErrFileNotFound, ErrDriveNotMounted},
+		{`2`, ErrFileNotFound, ErrDirectoryNotFound},
+		{`"2"`, ErrFileNotFound, ErrDirectoryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/rr_fileinfo":
+					fmt.Fprintf(w, `{"err":%s,"size":1,"lastModified":"2024-01-01T00:00:00"}`, tt.err)
+				case "/rr_filelist":
+					fmt.Fprintf(w, `{"dir":"0:/gcodes","first":0,"files":[],"next":0,"err":%s}`, tt.err)
+				}
+			})
+			fi, err := r.Fileinfo("0:/gcodes/a.g")
+			if err != tt.fileinfo {
+				t.Errorf("Fileinfo err = %v, want %v", err, tt.fileinfo)
+			}
+			if err == nil && fi.Size != 1 {
+				t.Errorf("Fileinfo size = %d, want 1", fi.Size)
+			}
+			fl, err := r.Filelist("0:/gcodes", false)
+			if err != tt.filelist {
+				t.Errorf("Filelist err = %v, want %v", err, tt.filelist)
+			}
+			if err == nil && fl.Dir != "0:/gcodes" {
+				t.Errorf("Filelist dir = %q", fl.Dir)
+			}
+		})
+	}
+}
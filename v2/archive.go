@@ -0,0 +1,131 @@
+package librfm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat selects the container format written by DownloadArchive
+type ArchiveFormat int
+
+const (
+	// ArchiveTar writes an uncompressed tar stream
+	ArchiveTar ArchiveFormat = iota
+	// ArchiveZip writes a zip archive with deflate compressed entries
+	ArchiveZip
+)
+
+// ErrUnknownArchiveFormat is the error returned for an unsupported ArchiveFormat
+var ErrUnknownArchiveFormat = errors.New("Unknown archive format")
+
+// archiveWriter abstracts the differences between tar and zip archives
+type archiveWriter interface {
+	addDir(name string, mtime time.Time) error
+	addFile(name string, size uint64, mtime time.Time) (io.Writer, error)
+	Close() error
+}
+
+// DownloadArchive recursively downloads dir and writes all its files and directories
+// as entries of an archive in the given format to w. Entries are named by their path
+// relative to dir and carry the modification times reported by the board. Every file
+// is streamed from the board into the archive so neither the tree nor single files
+// are held in memory or written to disk. Empty directories get entries of their own.
+func (r *RRFFileManager) DownloadArchive(ctx context.Context, dir string, w io.Writer, format ArchiveFormat) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	var aw archiveWriter
+	switch format {
+	case ArchiveTar:
+		aw = &tarArchive{tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = &zipArchive{zip.NewWriter(w)}
+	default:
+		return ErrUnknownArchiveFormat
+	}
+	fl, err := r.Filelist(ctx, dir, true)
+	if err != nil {
+		return err
+	}
+	root := cleanPath(fl.Dir)
+	err = fl.Walk(func(dir string, f File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		remote := JoinPath(dir, f.Name)
+		name := strings.TrimPrefix(strings.TrimPrefix(remote, root), "/")
+		if f.IsDir() {
+			return aw.addDir(name+"/", f.Date())
+		}
+		entry, err := aw.addFile(name, f.Size, f.Date())
+		if err != nil {
+			return err
+		}
+		return r.downloadTo(ctx, remote, entry)
+	})
+	if err != nil {
+		return err
+	}
+	return aw.Close()
+}
+
+// downloadTo streams the content of the file at path to w
+func (r *RRFFileManager) downloadTo(ctx context.Context, path string, w io.Writer) error {
+	vals := r.query("name", cleanPath(path))
+	var n int64
+	_, err := r.roundTrip(ctx, http.MethodGet, fmt.Sprintf(downloadURL, r.baseURL, vals), nil, nil, 0, func(_ int, body io.Reader) error {
+		var err error
+		n, err = io.Copy(w, body)
+		return err
+	})
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+		err = ErrFileNotFound
+	}
+	if err != nil {
+		return r.mountError(ctx, path, err)
+	}
+	r.stats.addDownload(n)
+	return nil
+}
+
+type tarArchive struct {
+	tw *tar.Writer
+}
+
+func (a *tarArchive) addDir(name string, mtime time.Time) error {
+	return a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755, ModTime: mtime})
+}
+
+func (a *tarArchive) addFile(name string, size uint64, mtime time.Time) (io.Writer, error) {
+	err := a.tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(size), ModTime: mtime})
+	return a.tw, err
+}
+
+func (a *tarArchive) Close() error {
+	return a.tw.Close()
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) addDir(name string, mtime time.Time) error {
+	_, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Modified: mtime})
+	return err
+}
+
+func (a *zipArchive) addFile(name string, _ uint64, mtime time.Time) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mtime})
+}
+
+func (a *zipArchive) Close() error {
+	return a.zw.Close()
+}
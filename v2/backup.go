@@ -0,0 +1,47 @@
+package librfm
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+)
+
+// BackupSys recursively downloads the system directory configured on the board to
+// destDir preserving its structure and the modification times of all files. Files
+// that cannot be downloaded are skipped with a logged warning.
+func (r *RRFFileManager) BackupSys(ctx context.Context, destDir string) error {
+	return r.backup(ctx, r.directories(ctx).System, destDir)
+}
+
+// backup recursively downloads remoteDir to destDir
+func (r *RRFFileManager) backup(ctx context.Context, remoteDir, destDir string) error {
+	fl, err := r.Filelist(ctx, remoteDir, true)
+	if err != nil {
+		return err
+	}
+	root := cleanPath(fl.Dir)
+	return fl.Walk(func(dir string, f File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		remote := JoinPath(dir, f.Name)
+		rel := strings.TrimPrefix(strings.TrimPrefix(cleanPath(remote), root), "/")
+		local, err := joinLocal(destDir, rel)
+		if err != nil {
+			log.Printf("Skipping %s: %s", remote, err)
+			return nil
+		}
+		if f.IsDir() {
+			return os.MkdirAll(local, 0755)
+		}
+		if _, err := r.DownloadToFile(ctx, remote, local); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Skipping %s: %s", remote, err)
+			return nil
+		}
+		return os.Chtimes(local, f.Date(), f.Date())
+	})
+}
@@ -0,0 +1,213 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DownloadAll downloads the given remote paths into the local directory dest
+// using at most concurrency parallel requests. The remote directory structure
+// below the volume root is recreated inside dest and paths that would end up
+// outside of it fail with ErrOutsideDestination. Paths on different volumes that
+// would end up in the same local file fail with ErrDuplicateDestination and are
+// not downloaded at all. The returned map contains the result for every path that
+// was attempted so a single failure does not abort the whole batch. The error is
+// only non-nil if dest could not be created or ctx was cancelled before all paths
+// were processed.
+func (r *RRFFileManager) DownloadAll(ctx context.Context, paths []string, dest string, concurrency int) (map[string]error, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+
+	// Local targets are resolved up front so no two downloads write the same file
+	results := make(map[string]error, len(paths))
+	locals := make(map[string]string, len(paths))
+	claims := make(map[string][]string, len(paths))
+	unique := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if _, ok := locals[p]; ok {
+			continue
+		}
+		local, err := localPath(dest, p)
+		if err != nil {
+			results[p] = err
+			continue
+		}
+		locals[p] = local
+		claims[local] = append(claims[local], p)
+		unique = append(unique, p)
+	}
+	todo := make([]string, 0, len(unique))
+	for _, p := range unique {
+		if len(claims[locals[p]]) > 1 {
+			results[p] = ErrDuplicateDestination
+			continue
+		}
+		todo = append(todo, p)
+	}
+
+	var mu sync.Mutex
+	forEach(ctx, todo, concurrency, func(p string) {
+		_, err := r.DownloadToFile(ctx, p, locals[p])
+		mu.Lock()
+		results[p] = err
+		mu.Unlock()
+	})
+	return results, ctx.Err()
+}
+
+// FileinfoAll fetches the Fileinfo of all given paths using at most concurrency
+// parallel requests which are additionally limited by WithMaxConcurrentRequests.
+// Results are keyed by the paths as given. Every path ends up in exactly one of the
+// maps and paths not attempted because ctx was cancelled are reported with ctx.Err().
+func (r *RRFFileManager) FileinfoAll(ctx context.Context, paths []string, concurrency int) (map[string]*Fileinfo, map[string]error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	infos := make(map[string]*Fileinfo, len(paths))
+	errs := make(map[string]error)
+	forEach(ctx, paths, concurrency, func(p string) {
+		info, err := r.Fileinfo(ctx, p)
+		mu.Lock()
+		if err != nil {
+			errs[p] = err
+		} else {
+			infos[p] = info
+		}
+		mu.Unlock()
+	})
+	if err := ctx.Err(); err != nil {
+		for _, p := range paths {
+			if _, ok := infos[p]; !ok && errs[p] == nil {
+				errs[p] = err
+			}
+		}
+	}
+	return infos, errs
+}
+
+// ErrOutsideDestination is the error returned if a remote path would be written
+// outside of the local destination directory, e.g. because it contains ".."
+var ErrOutsideDestination = errors.New("Path leaves destination directory")
+
+// ErrDuplicateDestination is the error returned for remote paths that would be
+// written to the same local file, e.g. the same path on different volumes
+var ErrDuplicateDestination = errors.New("Several paths share the same destination")
+
+// localPath maps the remote path p to a path below the local directory dest
+func localPath(dest, p string) (string, error) {
+	_, rest := splitVolume(cleanPath(p))
+	return joinLocal(dest, strings.TrimPrefix(rest, "/"))
+}
+
+// joinLocal joins the slash separated relative path rel onto the local directory
+// dest and fails with ErrOutsideDestination if the result is not below dest
+func joinLocal(dest, rel string) (string, error) {
+	local := filepath.Join(dest, filepath.FromSlash(rel))
+	r, err := filepath.Rel(filepath.Clean(dest), local)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) || filepath.IsAbs(r) {
+		return "", ErrOutsideDestination
+	}
+	return local, nil
+}
+
+// forEach calls fn for every item using a pool of concurrency workers. It stops
+// handing out new items once ctx is done and waits for running calls to finish.
+func forEach(ctx context.Context, items []string, concurrency int, fn func(string)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range jobs {
+				fn(item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case jobs <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// UploadDir uploads the contents of the local directory localDir to remoteDir
+// creating all missing directories. RRF cannot extract archives so every file is
+// uploaded with a request of its own using as many parallel requests as allowed by
+// WithMaxConcurrentRequests. The returned map contains the result for every remote
+// file path that was attempted. The error is only non-nil if the directories could
+// not be created or ctx was cancelled before all files were processed.
+func (r *RRFFileManager) UploadDir(ctx context.Context, localDir, remoteDir string) (map[string]error, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	remoteDir = r.resolvePath(remoteDir)
+	if err := r.MkdirAll(ctx, remoteDir); err != nil {
+		return nil, err
+	}
+
+	// Directories are created while walking so parents exist before their children
+	files := make(map[string]string)
+	targets := make([]string, 0)
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := JoinPath(cleanPath(remoteDir), filepath.ToSlash(rel))
+		if d.IsDir() {
+			return r.EnsureDir(ctx, target)
+		}
+		files[target] = p
+		targets = append(targets, target)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := r.maxRequests
+	if concurrency <= 0 {
+		concurrency = defaultMaxRequests
+	}
+	var mu sync.Mutex
+	results := make(map[string]error, len(targets))
+	forEach(ctx, targets, concurrency, func(target string) {
+		err := r.uploadFile(ctx, files[target], target)
+		mu.Lock()
+		results[target] = err
+		mu.Unlock()
+	})
+	return results, ctx.Err()
+}
+
+// uploadFile uploads the local file p to target
+func (r *RRFFileManager) uploadFile(ctx context.Context, p, target string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = r.Upload(ctx, target, f)
+	return err
+}
@@ -0,0 +1,67 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLocalPathStaysInDestination(t *testing.T) {
+	dest := t.TempDir()
+	tests := []struct {
+		remote string
+		want   string
+		err    error
+	}{
+		{"0:/gcodes/a.g", filepath.Join(dest, "gcodes", "a.g"), nil},
+		{"0:/gcodes/../sys/config.g", filepath.Join(dest, "sys", "config.g"), nil},
+		{"0:/../../etc/passwd", filepath.Join(dest, "etc", "passwd"), nil},
+		{"gcodes/a.g", filepath.Join(dest, "gcodes", "a.g"), nil},
+		{"../a.g", "", ErrOutsideDestination},
+		{"gcodes/../../a.g", "", ErrOutsideDestination},
+		{`..\..\a.g`, "", ErrOutsideDestination},
+		{"..", "", ErrOutsideDestination},
+	}
+	for _, tt := range tests {
+		got, err := localPath(dest, tt.remote)
+		if err != tt.err || got != tt.want {
+			t.Errorf("localPath(%q) = %q, %v; want %q, %v", tt.remote, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDownloadAllDuplicateDestinations(t *testing.T) {
+	var mu sync.Mutex
+	var downloaded []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		downloaded = append(downloaded, req.URL.Query().Get("name"))
+		mu.Unlock()
+		fmt.Fprint(w, "G28")
+	})
+
+	dest := t.TempDir()
+	paths := []string{"0:/macros/a.g", "1:/macros/a.g", "0:/macros/b.g", "0:/macros/b.g"}
+	results, err := r.DownloadAll(context.Background(), paths, dest, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]error{
+		"0:/macros/a.g": ErrDuplicateDestination,
+		"1:/macros/a.g": ErrDuplicateDestination,
+		"0:/macros/b.g": nil,
+	}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+	if want := []string{"0:/macros/b.g"}; fmt.Sprint(downloaded) != fmt.Sprint(want) {
+		t.Errorf("downloaded %q, want %q", downloaded, want)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "macros", "a.g")); !os.IsNotExist(err) {
+		t.Errorf("a.g written to dest: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeNotSet is the error returned if the board's clock has not been set
+var ErrTimeNotSet = errors.New("Board time not set")
+
+// BoardTime returns the current time of the board's clock read from the object
+// model. Like all timestamps from RRF it does not carry timezone information and
+// is interpreted in the timezone set with WithLocation, local time by default, so
+// comparing it to time.Now() reveals clock skew.
+func (r *RRFFileManager) BoardTime(ctx context.Context) (time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var s *string
+	if err := r.getModel(ctx, "state.time", "", &s); err != nil {
+		return time.Time{}, err
+	}
+	if s == nil || *s == "" {
+		return time.Time{}, ErrTimeNotSet
+	}
+	return time.ParseInLocation(TimeFormat, *s, r.location)
+}
+
+// BoardInfo describes the main board and the firmware running on it as reported
+// by the object model
+type BoardInfo struct {
+	// Name is the full name of the board, e.g. "Duet 3 MB6HC"
+	Name string
+	// ShortName is the short board identifier, e.g. "MB6HC"
+	ShortName string
+	// FirmwareName is the name of the firmware, i.e. "RepRapFirmware"
+	FirmwareName string
+	// FirmwareVersion is the version of the firmware, e.g. "3.5.1"
+	FirmwareVersion string
+	// FirmwareDate is the build date of the firmware
+	FirmwareDate string
+	// UniqueID is the processor's unique ID if the board reports it
+	UniqueID string `json:"uniqueId"`
+}
+
+// BoardInfo returns information on the main board and its firmware read from the
+// object model. It fails with ErrNoObjectModel on firmware older than RRF 3.0.
+func (r *RRFFileManager) BoardInfo(ctx context.Context) (*BoardInfo, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var b BoardInfo
+	if err := r.getModel(ctx, "boards[0]", "v", &b); err != nil {
+		return nil, err
+	}
+	if b.FirmwareVersion != "" {
+		r.fwMu.Lock()
+		r.fwVersion = b.FirmwareVersion
+		r.fwMu.Unlock()
+	}
+	return &b, nil
+}
+
+// SetBoardTime sets the board's clock to t with M905. The time is converted to the
+// timezone set with WithLocation, local time by default, since RRF keeps no timezone.
+// It returns a *ResponseError carrying the board's reply if M905 was rejected.
+func (r *RRFFileManager) SetBoardTime(ctx context.Context, t time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	t = t.In(r.location)
+	code := fmt.Sprintf(`M905 P"%s" S"%s"`, t.Format("2006-01-02"), t.Format("15:04:05"))
+	reply, err := r.RunGCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	return gcodeError(code, reply)
+}
@@ -0,0 +1,105 @@
+package librfm
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity up to which buffers are returned to the pool
+// so a single huge upload does not keep its memory alive
+const maxPooledBuffer = 4 << 20
+
+// bufferPool holds buffers reused to slurp upload content
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool. It must be given back with
+// putBuffer once nothing refers to its content anymore.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// sharedBuffer hands out request bodies reading the content of a pooled buffer.
+// The buffer is only given back to the pool once its owner and all bodies have
+// been released since the transport may still read or close a body after the
+// response was returned.
+type sharedBuffer struct {
+	buf  *bytes.Buffer
+	mu   sync.Mutex
+	refs int
+}
+
+// newSharedBuffer takes ownership of buf. The caller must call release once it
+// does not need new bodies anymore.
+func newSharedBuffer(buf *bytes.Buffer) *sharedBuffer {
+	return &sharedBuffer{buf: buf, refs: 1}
+}
+
+// Len returns the number of bytes of the content
+func (s *sharedBuffer) Len() int {
+	return s.buf.Len()
+}
+
+// body returns a new reader over the whole content which releases its reference
+// to s when closed
+func (s *sharedBuffer) body() io.ReadCloser {
+	s.mu.Lock()
+	s.refs++
+	s.mu.Unlock()
+	return &bufferBody{r: bytes.NewReader(s.buf.Bytes()), s: s}
+}
+
+// release drops a reference and returns the buffer to the pool with the last one
+func (s *sharedBuffer) release() {
+	s.mu.Lock()
+	s.refs--
+	done := s.refs == 0
+	s.mu.Unlock()
+	if done {
+		putBuffer(s.buf)
+	}
+}
+
+// bufferBody is a request body created by sharedBuffer.body. The transport may
+// close it while another goroutine is still reading so both are synchronized.
+type bufferBody struct {
+	mu     sync.Mutex
+	r      *bytes.Reader
+	s      *sharedBuffer
+	closed bool
+}
+
+func (b *bufferBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	return b.r.Read(p)
+}
+
+// Close releases the body's reference to its buffer. Further reads fail.
+func (b *bufferBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.r = nil
+		b.s.release()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package librfm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSharedBufferOutlivesOwner(t *testing.T) {
+	b := getBuffer()
+	b.WriteString("content")
+	s := newSharedBuffer(b)
+	body := s.body()
+	s.release()
+
+	// The buffer must not be handed out again while body is still open
+	for i := 0; i < 100; i++ {
+		if other := getBuffer(); other == b {
+			t.Fatal("buffer returned to pool before body was closed")
+		}
+	}
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "content" {
+		t.Errorf("body = %q, want %q", got, "content")
+	}
+	body.Close()
+	if _, err := body.Read(make([]byte, 1)); err != http.ErrBodyReadAfterClose {
+		t.Errorf("read after close returned %v", err)
+	}
+}
+
+// crcHandler answers rr_upload requests with an error if the body does not
+// match the crc32 sent along
+func crcHandler(w http.ResponseWriter, req *http.Request) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	want, _ := strconv.ParseUint(req.URL.Query().Get("crc32"), 16, 32)
+	if crc32.ChecksumIEEE(b) != uint32(want) || int64(len(b)) != req.ContentLength {
+		fmt.Fprint(w, `{"err":1}`)
+		return
+	}
+	fmt.Fprint(w, `{"err":0}`)
+}
+
+func TestConcurrentUploadsKeepContent(t *testing.T) {
+	r := newTestManager(t, crcHandler, WithMaxConcurrentRequests(0))
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := bytes.Repeat([]byte{byte(i)}, 1024+i)
+			_, err := r.Upload(context.Background(), fmt.Sprintf("0:/gcodes/%d.g", i), bytes.NewReader(content))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func BenchmarkUploadSmall(b *testing.B) {
+	r := newTestManager(b, crcHandler)
+	content := bytes.Repeat([]byte("G1 X1 Y1\n"), 100)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000; j++ {
+			if _, err := r.Upload(ctx, "0:/gcodes/small.g", bytes.NewReader(content)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
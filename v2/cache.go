@@ -0,0 +1,81 @@
+package librfm
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type cachedFileinfo struct {
+	info    Fileinfo
+	expires time.Time
+}
+
+// fileinfoCache keeps results of Fileinfo for a limited time
+type fileinfoCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedFileinfo
+}
+
+func newFileinfoCache(ttl time.Duration) *fileinfoCache {
+	return &fileinfoCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedFileinfo),
+	}
+}
+
+// get returns a copy of the cached Fileinfo for path if it has not expired yet
+func (c *fileinfoCache) get(path string) (*Fileinfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, path)
+		return nil, false
+	}
+	return e.info.clone(), true
+}
+
+func (c *fileinfoCache) put(path string, info *Fileinfo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[path] = cachedFileinfo{info: *info.clone(), expires: time.Now().Add(c.ttl)}
+}
+
+// invalidate removes path and everything below it from the cache
+func (c *fileinfoCache) invalidate(path string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	for p := range c.entries {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(c.entries, p)
+		}
+	}
+}
+
+// clone returns a copy of f that shares no slices with it so neither the cache nor
+// its callers can modify what the other one holds
+func (f *Fileinfo) clone() *Fileinfo {
+	c := *f
+	if f.Filament != nil {
+		c.Filament = append(FilamentLengths(nil), f.Filament...)
+	}
+	if f.Thumbnails != nil {
+		c.Thumbnails = append([]Thumbnail(nil), f.Thumbnails...)
+	}
+	return &c
+}
@@ -0,0 +1,72 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFileinfoCacheIsolatesSlices(t *testing.T) {
+	requests := 0
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"err":0,"size":1,"filament":[100.5,200.5],"thumbnails":[{"width":32,"height":32,"fmt":"qoi","offset":1,"size":2}]}`)
+	}, WithFileinfoCache(time.Minute))
+	ctx := context.Background()
+
+	first, err := r.Fileinfo(ctx, "0:/gcodes/a.g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Filament[0] = 0
+	first.Thumbnails[0].Width = 0
+
+	second, err := r.Fileinfo(ctx, "0:/gcodes/a.g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Fatalf("%d requests, want the second call to be served from the cache", requests)
+	}
+	if second.Filament[0] != 100.5 || second.Thumbnails[0].Width != 32 {
+		t.Errorf("cache was modified through the first result: %+v", second)
+	}
+	second.Filament[1] = 0
+
+	third, _ := r.Fileinfo(ctx, "0:/gcodes/a.g")
+	if third.Filament[1] != 200.5 {
+		t.Errorf("cache was modified through the second result: %+v", third)
+	}
+}
+
+func TestDeleteIfExistsInvalidatesCache(t *testing.T) {
+	exists := true
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_fileinfo":
+			if !exists {
+				fmt.Fprint(w, `{"err":1}`)
+				return
+			}
+			fmt.Fprint(w, `{"err":0,"size":1}`)
+		case "/rr_delete":
+			exists = false
+			fmt.Fprint(w, `{"err":0}`)
+		case "/rr_model":
+			fmt.Fprint(w, `{"key":"volumes[0]","flags":"","result":{"mounted":true}}`)
+		}
+	}, WithFileinfoCache(time.Minute))
+	ctx := context.Background()
+
+	if _, err := r.Fileinfo(ctx, "0:/gcodes/a.g"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteIfExists(ctx, "0:/gcodes/a.g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Fileinfo(ctx, "0:/gcodes/a.g"); err != ErrFileNotFound {
+		t.Errorf("Fileinfo after DeleteIfExists = %v, want ErrFileNotFound", err)
+	}
+}
@@ -0,0 +1,236 @@
+package librfm
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"time"
+)
+
+// Client is the set of context-aware operations offered by RRFFileManager. Code
+// that only needs to interact with a board can depend on this interface and
+// substitute a fake implementation in tests.
+type Client interface {
+	// Connect establishes a connection to RepRapFirmware
+	Connect(ctx context.Context, password string) error
+
+	// ConnectResult establishes a connection and returns what the board reported
+	ConnectResult(ctx context.Context, password string) (*ConnectInfo, error)
+
+	// ConnectResilient connects retrying with backoff while the board cannot be reached
+	ConnectResilient(ctx context.Context, password string, opts ConnectRetryOptions) (*ConnectInfo, error)
+
+	// SessionTimeout returns the session timeout reported by the board
+	SessionTimeout() time.Duration
+
+	// Done returns a channel that is closed once the session is lost or a drive unmounted
+	Done() <-chan struct{}
+
+	// Close ends the session and releases idle connections and the keepalive
+	Close() error
+
+	// Warmup opens a connection to the board ahead of the first real operation
+	Warmup(ctx context.Context) (*time.Duration, error)
+
+	// Do sends a GET request to an arbitrary endpoint and returns the raw response
+	Do(ctx context.Context, endpoint string, params url.Values) ([]byte, *time.Duration, error)
+
+	// DoQuery sends a GET request with a raw query keeping the order of its parameters
+	DoQuery(ctx context.Context, endpoint, rawQuery string) ([]byte, *time.Duration, error)
+
+	// FirmwareVersion returns the version of RepRapFirmware running on the board
+	FirmwareVersion(ctx context.Context) (string, error)
+
+	// SupportsSessionKeys returns true if the firmware supports session keys
+	SupportsSessionKeys(ctx context.Context) (bool, error)
+
+	// SupportsObjectModel returns true if the firmware can be queried through the object model
+	SupportsObjectModel(ctx context.Context) (bool, error)
+
+	// BoardTime returns the current time of the board's clock
+	BoardTime(ctx context.Context) (time.Time, error)
+
+	// SetBoardTime sets the board's clock
+	SetBoardTime(ctx context.Context, t time.Time) error
+
+	// BoardInfo returns information on the main board and its firmware
+	BoardInfo(ctx context.Context) (*BoardInfo, error)
+
+	// Status queries the legacy rr_status endpoint of RRF 2.x
+	Status(ctx context.Context, level int) (*Status, error)
+
+	// PrintProgress returns the progress of the currently running print job
+	PrintProgress(ctx context.Context) (*Progress, error)
+
+	// CurrentJob returns the path of the file being printed or an empty string
+	CurrentJob(ctx context.Context) (string, error)
+
+	// RunGCode sends a G-code to the board and returns its reply
+	RunGCode(ctx context.Context, code string) (string, error)
+
+	// MountVolume mounts the SD card in the given volume
+	MountVolume(ctx context.Context, volume int) error
+
+	// UnmountVolume unmounts the SD card in the given volume
+	UnmountVolume(ctx context.Context, volume int) error
+
+	// CanWrite checks whether the given volume is mounted and writable
+	CanWrite(ctx context.Context, volume int) (bool, error)
+
+	// Filelist will download a list of all files (also including directories) for the given path.
+	// If recursive is true it will also populate the field Subdirs of Filelist to contain the full
+	// tree.
+	Filelist(ctx context.Context, dir string, recursive bool) (*Filelist, error)
+
+	// FilelistWithOptions works like Filelist but allows to choose the sort order
+	FilelistWithOptions(ctx context.Context, dir string, opts FilelistOptions) (*Filelist, error)
+
+	// FilelistIterator returns an iterator fetching a listing page by page
+	FilelistIterator(ctx context.Context, dir string) *FileIterator
+
+	// FilelistSince works like Filelist but only keeps files modified after since
+	FilelistSince(ctx context.Context, dir string, since time.Time, recursive bool) (*Filelist, error)
+
+	// ListDirs returns only the directory entries of dir
+	ListDirs(ctx context.Context, dir string) ([]File, error)
+
+	// DirStats counts files and directories below dir and sums up their size
+	DirStats(ctx context.Context, dir string, recursive bool) (files int, dirs int, totalSize uint64, err error)
+
+	// RecentFiles returns the n most recently modified files below dir
+	RecentFiles(ctx context.Context, dir string, n int) ([]File, error)
+
+	// DirLastModified returns the last modification time of a directory
+	DirLastModified(ctx context.Context, dir string) (time.Time, error)
+
+	// Exists checks whether a file or directory with the given path exists
+	Exists(ctx context.Context, path string) (bool, error)
+
+	// IsEmpty returns whether a directory has no entries
+	IsEmpty(ctx context.Context, dir string) (bool, error)
+
+	// WaitForFile polls until a file or directory with the given path exists
+	WaitForFile(ctx context.Context, path string, pollInterval time.Duration) error
+
+	// Fileinfo returns information on a given file or an error if the file does not exist
+	Fileinfo(ctx context.Context, path string) (*Fileinfo, error)
+
+	// Download downloads a file with the given path also returning the duration of this action
+	Download(ctx context.Context, path string) ([]byte, *time.Duration, error)
+
+	// DownloadWithType downloads a file and also returns its content type
+	DownloadWithType(ctx context.Context, path string) ([]byte, string, *time.Duration, error)
+
+	// DownloadIfModified downloads a file only if it was modified after since
+	DownloadIfModified(ctx context.Context, path string, since time.Time) ([]byte, bool, error)
+
+	// DownloadToFile downloads a file and writes it to a local path
+	DownloadToFile(ctx context.Context, remotePath, localPath string) (*time.Duration, error)
+
+	// DownloadToFileResume downloads a file to a local path resuming a partial download
+	DownloadToFileResume(ctx context.Context, remotePath, localPath string) error
+
+	// DownloadAll downloads the given paths into a local directory in parallel
+	DownloadAll(ctx context.Context, paths []string, dest string, concurrency int) (map[string]error, error)
+
+	// FileinfoAll fetches the Fileinfo of many files in parallel
+	FileinfoAll(ctx context.Context, paths []string, concurrency int) (map[string]*Fileinfo, map[string]error)
+
+	// BackupSys recursively downloads the system directory to a local directory
+	BackupSys(ctx context.Context, destDir string) error
+
+	// Mkdir creates a new directory with the given path
+	Mkdir(ctx context.Context, path string) error
+
+	// MkdirAll creates a directory along with all missing parents
+	MkdirAll(ctx context.Context, path string) error
+
+	// EnsureDir creates the given directory unless it already exists
+	EnsureDir(ctx context.Context, path string) error
+
+	// Move renames or moves a file or directory (only within the same SD card)
+	Move(ctx context.Context, oldpath, newpath string) error
+
+	// Rename changes only the final element of path keeping it in the same directory
+	Rename(ctx context.Context, path, newName string) error
+
+	// Delete removes the given path. It will fail for non-empty directories.
+	Delete(ctx context.Context, path string) error
+
+	// DeleteIfExists removes the given path unless it does not exist
+	DeleteIfExists(ctx context.Context, path string) error
+
+	// DeleteRecursive removes the given path including all of its contents
+	DeleteRecursive(ctx context.Context, path string) error
+
+	// DeleteRecursiveWithProgress removes a path with all its contents reporting each deleted entry
+	DeleteRecursiveWithProgress(ctx context.Context, path string, progress func(path string)) error
+
+	// Upload uploads a new file to the given path on the SD card
+	Upload(ctx context.Context, path string, content io.Reader) (*time.Duration, error)
+
+	// UploadWithResult uploads a new file and returns its size and CRC32
+	UploadWithResult(ctx context.Context, path string, content io.Reader) (*UploadResult, error)
+
+	// UploadVerified uploads a new file and verifies it by comparing SHA256 sums
+	UploadVerified(ctx context.Context, path string, content io.Reader) (*time.Duration, error)
+
+	// UploadDir uploads the contents of a local directory
+	UploadDir(ctx context.Context, localDir, remoteDir string) (map[string]error, error)
+
+	// Sync mirrors a local directory to a directory on the board
+	Sync(ctx context.Context, localDir, remoteDir string, opts SyncOptions) (SyncReport, error)
+
+	// NeedsUpload compares a local file with its remote counterpart
+	NeedsUpload(ctx context.Context, localPath, remotePath string) (bool, string, error)
+
+	// UploadGzip uploads a gzip compressed file for proxies that decompress it before the board
+	UploadGzip(ctx context.Context, path string, content io.Reader) (*time.Duration, error)
+
+	// UploadUnique uploads a file under a suffixed name if the path is already taken
+	UploadUnique(ctx context.Context, path string, content io.Reader) (string, *time.Duration, error)
+
+	// UploadNoClobber uploads a file only if the path is not taken yet
+	UploadNoClobber(ctx context.Context, path string, content io.Reader) (*time.Duration, error)
+
+	// Filaments returns the names of the filament profiles on the board
+	Filaments(ctx context.Context) ([]string, error)
+
+	// FilamentConfig downloads a file of the named filament profile
+	FilamentConfig(ctx context.Context, name, file string) ([]byte, error)
+
+	// DownloadHeightmap downloads and parses the height map of mesh bed probing
+	DownloadHeightmap(ctx context.Context) ([][]float64, *HeightmapMeta, error)
+
+	// UploadHeightmap uploads a grid as the height map of mesh bed probing
+	UploadHeightmap(ctx context.Context, grid [][]float64, meta HeightmapMeta) error
+
+	// FindDuplicates groups the files below a directory that have identical content
+	FindDuplicates(ctx context.Context, dir string) (map[string][]string, error)
+
+	// DiskSpace returns the free and total number of bytes of the given volume
+	DiskSpace(ctx context.Context, volume int) (free, total uint64, err error)
+
+	// WillFit reports whether totalBytes fit on the given volume keeping margin bytes free
+	WillFit(ctx context.Context, totalBytes uint64, volume int, margin uint64) (fits bool, free uint64, err error)
+
+	// IsMounted returns whether the given volume is mounted
+	IsMounted(ctx context.Context, volume int) (bool, error)
+
+	// DownloadArchive streams a remote directory into a tar or zip archive
+	DownloadArchive(ctx context.Context, dir string, w io.Writer, format ArchiveFormat) error
+
+	// SetWorkingDir sets the directory relative paths are resolved against
+	SetWorkingDir(dir string)
+
+	// WorkingDir returns the directory relative paths are resolved against
+	WorkingDir() string
+
+	// Stats returns the number of files and bytes transferred so far
+	Stats() ManagerStats
+
+	// Directories returns the directories configured on the board
+	Directories(ctx context.Context) (*Directories, error)
+}
+
+var _ Client = (*RRFFileManager)(nil)
@@ -0,0 +1,292 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	errInvalidPassword = 1
+	errNoFreeSession   = 2
+	// defaultSessionTimeout is used if the board did not report its session timeout
+	defaultSessionTimeout = 8 * time.Second
+	// defaultConnectInterval is the minimum time between two connect attempts
+	defaultConnectInterval = 2 * time.Second
+)
+
+// ErrInvalidPassword is the error returned by Connect if the board rejected the password
+var ErrInvalidPassword = errors.New("Invalid password")
+
+// ErrNoFreeSession is the error returned by Connect if the board has no more sessions available
+var ErrNoFreeSession = errors.New("No free session available")
+
+// ConnectInfo contains what the board reported when establishing a connection
+type ConnectInfo struct {
+	// Err holds a numeric error code where 0 means no error
+	Err ErrorCode
+	// SessionKey identifies this session on firmware supporting session keys (0 otherwise)
+	SessionKey uint64
+	// SessionTimeout is the time after which the board drops an idle session
+	SessionTimeout time.Duration
+	// APILevel is the level of the HTTP API supported by the board
+	APILevel int
+	// BoardType is the type of the board, e.g. "duet3mb6hc101"
+	BoardType string
+}
+
+type connectResponse struct {
+	Err            ErrorCode
+	SessionTimeout uint64
+	BoardType      string
+	APILevel       int
+	SessionKey     uint64
+}
+
+// ConnectResult establishes a connection to RepRapFirmware and returns what the
+// board reported in its response. If the board hands out a session key it will
+// be sent along with all subsequent requests.
+func (r *RRFFileManager) ConnectResult(ctx context.Context, password string) (*ConnectInfo, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if err := r.waitConnectInterval(ctx); err != nil {
+		return nil, err
+	}
+	vals := r.query("password", password, "time", r.getTimestamp())
+	r.fwMu.Lock()
+	r.fwVersion = ""
+	r.fwGen++
+	r.fwMu.Unlock()
+	r.setSessionKey(0)
+	body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(connectURL, r.baseURL, vals))
+	if err != nil {
+		return nil, err
+	}
+
+	var c connectResponse
+	if err := r.decode(body, &c); err != nil {
+		return nil, err
+	}
+	info := &ConnectInfo{
+		Err:            c.Err,
+		SessionKey:     c.SessionKey,
+		SessionTimeout: time.Duration(c.SessionTimeout) * time.Millisecond,
+		APILevel:       c.APILevel,
+		BoardType:      c.BoardType,
+	}
+	switch c.Err {
+	case 0:
+	case errInvalidPassword:
+		return info, ErrInvalidPassword
+	case errNoFreeSession:
+		return info, ErrNoFreeSession
+	default:
+		return info, fmt.Errorf("Failed to perform: Connect (err %d)", c.Err)
+	}
+	r.setSessionKey(c.SessionKey)
+	r.sessMu.Lock()
+	r.connected = true
+	r.sessMu.Unlock()
+	r.setSessionTimeout(info.SessionTimeout)
+	r.resetSessionLost()
+	if r.keepalive {
+		r.startKeepalive()
+	}
+	return info, nil
+}
+
+// waitConnectInterval delays a connect attempt until the configured minimum
+// interval since the previous attempt has passed since some firmware rejects
+// connects in quick succession
+func (r *RRFFileManager) waitConnectInterval(ctx context.Context) error {
+	r.sessMu.Lock()
+	wait := time.Until(r.lastConnect.Add(r.connectInterval))
+	if wait <= 0 {
+		r.lastConnect = time.Now()
+		r.sessMu.Unlock()
+		return nil
+	}
+	r.lastConnect = time.Now().Add(wait)
+	r.sessMu.Unlock()
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SessionTimeout returns the time after which the board drops an idle session as
+// reported on the last successful Connect. It returns a default of 8s if the board
+// did not report a timeout or no connection has been established yet.
+func (r *RRFFileManager) SessionTimeout() time.Duration {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	if r.sessionTimeout <= 0 {
+		return defaultSessionTimeout
+	}
+	return r.sessionTimeout
+}
+
+func (r *RRFFileManager) setSessionTimeout(timeout time.Duration) {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	r.sessionTimeout = timeout
+}
+
+// startKeepalive starts a goroutine sending a cheap request at half the session
+// timeout so the board does not drop the session. A previously started keepalive
+// is stopped.
+func (r *RRFFileManager) startKeepalive() {
+	r.stopKeepalive()
+	interval := r.SessionTimeout() / 2
+	stop := make(chan struct{})
+	r.sessMu.Lock()
+	r.keepaliveStop = stop
+	r.sessMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				_, err := r.Warmup(ctx)
+				cancel()
+				if err != nil && r.debug {
+					log.Printf("Keepalive failed: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopKeepalive stops a running keepalive goroutine if any
+func (r *RRFFileManager) stopKeepalive() {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	if r.keepaliveStop != nil {
+		close(r.keepaliveStop)
+		r.keepaliveStop = nil
+	}
+}
+
+// Done returns a channel that is closed as soon as the manager observes that the
+// session was lost (the board answered with 401 Unauthorized) or a drive is no
+// longer mounted. A supervising goroutine can wait on it to reconnect. After a
+// successful Connect subsequent calls return a new channel.
+func (r *RRFFileManager) Done() <-chan struct{} {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	if r.lost == nil {
+		r.lost = make(chan struct{})
+	}
+	return r.lost
+}
+
+func (r *RRFFileManager) signalSessionLost() {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	if r.lost == nil {
+		r.lost = make(chan struct{})
+	}
+	if !r.lostClosed {
+		close(r.lost)
+		r.lostClosed = true
+	}
+}
+
+func (r *RRFFileManager) resetSessionLost() {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	if r.lostClosed {
+		r.lost = make(chan struct{})
+		r.lostClosed = false
+	}
+}
+
+func (r *RRFFileManager) getSessionKey() uint64 {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	return r.sessionKey
+}
+
+func (r *RRFFileManager) setSessionKey(key uint64) {
+	r.sessMu.Lock()
+	defer r.sessMu.Unlock()
+	r.sessionKey = key
+}
+
+// Close releases the resources held by the manager. It stops the keepalive, ends
+// the session on the board if a Connect succeeded and closes idle connections.
+// The manager should not be used afterwards.
+func (r *RRFFileManager) Close() error {
+	r.stopKeepalive()
+	r.sessMu.Lock()
+	connected := r.connected
+	r.connected = false
+	r.sessMu.Unlock()
+
+	var err error
+	if connected {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		_, _, err = r.doJSONRequest(ctx, fmt.Sprintf(disconnectURL, r.baseURL))
+		cancel()
+		r.setSessionKey(0)
+	}
+	r.httpClient.CloseIdleConnections()
+	return err
+}
+
+// ConnectRetryOptions control how ConnectResilient retries
+type ConnectRetryOptions struct {
+	// Attempts is the maximum number of connection attempts, 5 if not positive
+	Attempts int
+	// InitialDelay is the wait before the first retry, 500ms if not positive.
+	// It doubles with every further retry.
+	InitialDelay time.Duration
+	// MaxDelay caps the wait between two attempts, 8s if not positive
+	MaxDelay time.Duration
+}
+
+// ConnectResilient connects like ConnectResult but retries with exponential backoff
+// if the board could not be reached at all, e.g. because resolving an mDNS name like
+// "duet.local" failed or timed out on the first attempts. Errors reported by the
+// board like ErrInvalidPassword are returned immediately.
+func (r *RRFFileManager) ConnectResilient(ctx context.Context, password string, opts ConnectRetryOptions) (*ConnectInfo, error) {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 5
+	}
+	if opts.InitialDelay <= 0 {
+		opts.InitialDelay = 500 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 8 * time.Second
+	}
+	delay := opts.InitialDelay
+	for attempt := 1; ; attempt++ {
+		info, err := r.ConnectResult(ctx, password)
+		var terr *TransportError
+		if err == nil || attempt >= opts.Attempts || !errors.As(err, &terr) || ctx.Err() != nil {
+			return info, err
+		}
+		if r.debug {
+			log.Printf("Connect failed (%s), retrying in %s (%d/%d)", err, delay, attempt, opts.Attempts-1)
+		}
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay *= 2
+		if delay > opts.MaxDelay {
+			delay = opts.MaxDelay
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCloseDisconnectsOnlyEstablishedSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		connectErr string
+		disconnect bool
+	}{
+		{"connected", "0", true},
+		{"invalid password", "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disconnects := 0
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/rr_connect":
+					fmt.Fprintf(w, `{"err":%s,"sessionTimeout":8000}`, tt.connectErr)
+				case "/rr_disconnect":
+					disconnects++
+					fmt.Fprint(w, `{"err":0}`)
+				}
+			}, WithConnectInterval(0))
+			r.Connect(context.Background(), "secret")
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			if (disconnects == 1) != tt.disconnect {
+				t.Errorf("sent %d disconnects, want disconnect %t", disconnects, tt.disconnect)
+			}
+			if err := r.Close(); err != nil || disconnects > 1 {
+				t.Errorf("second Close = %v after %d disconnects", err, disconnects)
+			}
+		})
+	}
+}
+
+func TestCloseAfterUnreachableBoard(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	r := New("127.0.0.1", uint64(port), false, WithConnectInterval(0))
+	if _, err := r.ConnectResilient(context.Background(), "", ConnectRetryOptions{Attempts: 2, InitialDelay: time.Millisecond}); err == nil {
+		t.Fatal("connected to closed port " + strconv.Itoa(port))
+	}
+	start := time.Now()
+	if err := r.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %s", elapsed)
+	}
+}
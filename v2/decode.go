@@ -0,0 +1,143 @@
+package librfm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// ErrTrailingData is the error returned in strict decoding mode if a response
+// contains more data after the JSON value
+var ErrTrailingData = errors.New("Trailing data after JSON response")
+
+// jsonContentType is the content type of RRF's responses to rr_* metadata requests
+const jsonContentType = "application/json"
+
+// isJSON returns true if the content type ct of a response can hold JSON. Missing and
+// plain text types are accepted since some firmware versions send those for JSON.
+func isJSON(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	return mt == jsonContentType || mt == "text/json" || mt == "text/plain" || strings.HasSuffix(mt, "+json")
+}
+
+// decode unmarshals the JSON response body into v. In strict mode unknown fields
+// and trailing data are reported as errors to catch changes of the firmware's
+// responses. In lenient mode (the default) both are ignored.
+func (r *RRFFileManager) decode(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if r.strictDecoding {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if r.strictDecoding && dec.More() {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// decodeFilelist decodes an rr_filelist response from body into fl token by token so
+// only a single File entry has to be held in addition to the decoded list. Strict
+// mode is honored the same way as by decode, i.e. only fields of Filelist and File
+// are accepted.
+func (r *RRFFileManager) decodeFilelist(body io.Reader, fl *Filelist) error {
+	dec := json.NewDecoder(body)
+	if r.strictDecoding {
+		dec.DisallowUnknownFields()
+	}
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+
+		// Like json.Unmarshal field names are matched case-insensitively
+		switch strings.ToLower(key) {
+		case "dir":
+			err = dec.Decode(&fl.Dir)
+		case "first":
+			err = dec.Decode(&fl.First)
+		case "next":
+			err = dec.Decode(&fl.Next)
+		case "err":
+			err = dec.Decode(&fl.Err)
+		case "files":
+			err = decodeFiles(dec, fl)
+		default:
+			if r.strictDecoding {
+				return fmt.Errorf("json: unknown field %q", key)
+			}
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if r.strictDecoding && dec.More() {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// decodeFiles decodes the files array of an rr_filelist response one entry at a time
+func decodeFiles(dec *json.Decoder, fl *Filelist) error {
+	tok, err := dec.Token()
+	if err != nil || tok == nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("json: expected [ but got %v", tok)
+	}
+	fl.Files = make([]File, 0)
+	for dec.More() {
+		var f File
+		if err := dec.Decode(&f); err != nil {
+			return err
+		}
+		fl.Files = append(fl.Files, f)
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token from dec and fails if it is not delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("json: expected %s but got %v", delim, tok)
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
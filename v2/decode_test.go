@@ -0,0 +1,229 @@
+package librfm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Responses as sent by a Duet 2 WiFi running RepRapFirmware 3.4.5
+const (
+	rrf3Connect  = `{"err":0,"sessionTimeout":8000,"boardType":"duetwifi102","apiLevel":1,"sessionKey":3465163181}`
+	rrf3Filelist = `{"dir":"0:/gcodes","first":0,"files":[{"type":"d","name":"parts","size":0,"date":"2023-05-02T18:11:36"},{"type":"f","name":"benchy.gcode","size":4712231,"date":"2023-05-01T09:30:12"}],"next":0}`
+	rrf3Fileinfo = `{"err":0,"fileName":"0:/gcodes/benchy.gcode","size":4712231,"lastModified":"2023-05-01T09:30:12","height":48.00,"layerHeight":0.20,"numLayers":240,"printTime":6083,"simulatedTime":5911,"filament":[1854.3],"generatedBy":"PrusaSlicer 2.5.2+win64","thumbnails":[{"width":32,"height":32,"fmt":"qoi","offset":452,"size":1352},{"width":220,"height":220,"fmt":"qoi","offset":1888,"size":33872}]}`
+)
+
+// rrf3Handler serves the captured responses
+func rrf3Handler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	switch req.URL.Path {
+	case "/rr_connect":
+		fmt.Fprint(w, rrf3Connect)
+	case "/rr_filelist":
+		fmt.Fprint(w, rrf3Filelist)
+	case "/rr_fileinfo":
+		fmt.Fprint(w, rrf3Fileinfo)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func TestStrictDecodingRRF3(t *testing.T) {
+	ctx := context.Background()
+	r := newTestManager(t, rrf3Handler, WithStrictDecoding(true))
+
+	info, err := r.ConnectResult(ctx, "")
+	if err != nil {
+		t.Fatalf("ConnectResult: %v", err)
+	}
+	if info.SessionKey != 3465163181 || info.BoardType != "duetwifi102" {
+		t.Errorf("ConnectResult = %+v", info)
+	}
+
+	fl, err := r.Filelist(ctx, "0:/gcodes", false)
+	if err != nil {
+		t.Fatalf("Filelist: %v", err)
+	}
+	if len(fl.Files) != 2 || fl.Files[1].Name != "benchy.gcode" {
+		t.Errorf("Filelist files = %+v", fl.Files)
+	}
+
+	fi, err := r.Fileinfo(ctx, "0:/gcodes/benchy.gcode")
+	if err != nil {
+		t.Fatalf("Fileinfo: %v", err)
+	}
+	if fi.FileName != "0:/gcodes/benchy.gcode" || fi.NumLayers != 240 || fi.SimulatedTime != 5911 || len(fi.Thumbnails) != 2 || fi.Thumbnails[1].Format != "qoi" {
+		t.Errorf("Fileinfo = %+v", fi)
+	}
+}
+
+func TestStrictDecodingRejectsUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		call func(r *RRFFileManager) error
+	}{
+		{"filelist", `{"dir":"0:/","first":0,"files":[],"next":0,"bogus":1}`, func(r *RRFFileManager) error {
+			_, err := r.Filelist(context.Background(), "0:/", false)
+			return err
+		}},
+		{"file entry", `{"dir":"0:/","files":[{"type":"f","name":"a","size":1,"date":"2023-05-01T09:30:12","bogus":1}],"next":0}`, func(r *RRFFileManager) error {
+			_, err := r.Filelist(context.Background(), "0:/", false)
+			return err
+		}},
+		{"fileinfo", `{"err":0,"size":1,"bogus":1}`, func(r *RRFFileManager) error {
+			_, err := r.Fileinfo(context.Background(), "0:/a")
+			return err
+		}},
+		{"trailing data", `{"err":0,"size":1} {}`, func(r *RRFFileManager) error {
+			_, err := r.Fileinfo(context.Background(), "0:/a")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, req *http.Request) { fmt.Fprint(w, tt.body) }
+			if err := tt.call(newTestManager(t, handler)); err != nil {
+				t.Errorf("lenient mode failed: %v", err)
+			}
+			err := tt.call(newTestManager(t, handler, WithStrictDecoding(true)))
+			if err == nil || !(strings.Contains(err.Error(), "bogus") || err == ErrTrailingData) {
+				t.Errorf("strict mode returned %v", err)
+			}
+		})
+	}
+}
+
+func TestErrAsNumberOrString(t *testing.T) {
+	tests := []struct {
+		err      string
+		fileinfo error
+		filelist error
+	}{
+		{`0`, nil, nil},
+		{`"0"`, nil, nil},
+		{`1`, ErrFileNotFound, ErrDriveNotMounted},
+		{`"1"`, ErrFileNotFound, ErrDriveNotMounted},
+		{`2`, ErrFileNotFound, ErrDirectoryNotFound},
+		{`"2"`, ErrFileNotFound, ErrDirectoryNotFound},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s strict=%t", tt.err, strict), func(t *testing.T) {
+				r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+					switch req.URL.Path {
+					case "/rr_fileinfo":
+						fmt.Fprintf(w, `{"err":%s,"size":1,"lastModified":"2024-01-01T00:00:00"}`, tt.err)
+					case "/rr_filelist":
+						if req.URL.Query().Get("dir") != "0:/gcodes" {
+
+							// Root listing used to check whether the volume is mounted
+							fmt.Fprint(w, `{"dir":"0:/","first":0,"files":[],"next":0}`)
+							return
+						}
+						fmt.Fprintf(w, `{"dir":"0:/gcodes","first":0,"files":[],"next":0,"err":%s}`, tt.err)
+					default:
+						http.NotFound(w, req)
+					}
+				}, WithStrictDecoding(strict))
+				ctx := context.Background()
+				fi, err := r.Fileinfo(ctx, "0:/gcodes/a.g")
+				if err != tt.fileinfo {
+					t.Errorf("Fileinfo err = %v, want %v", err, tt.fileinfo)
+				}
+				if err == nil && fi.Size != 1 {
+					t.Errorf("Fileinfo size = %d, want 1", fi.Size)
+				}
+				fl, err := r.Filelist(ctx, "0:/gcodes", false)
+				if err != tt.filelist {
+					t.Errorf("Filelist err = %v, want %v", err, tt.filelist)
+				}
+				if err == nil && fl.Dir != "0:/gcodes" {
+					t.Errorf("Filelist dir = %q", fl.Dir)
+				}
+			})
+		}
+	}
+}
+
+// syntheticFilelist returns an rr_filelist response with n entries
+func syntheticFilelist(n int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"dir":"0:/gcodes","first":0,"files":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		typ := "f"
+		if i%10 == 0 {
+			typ = "d"
+		}
+		fmt.Fprintf(&sb, `{"type":%q,"name":"file%d.gcode","size":%d,"date":"2023-05-01T09:30:12"}`, typ, i, i*1000)
+	}
+	sb.WriteString(`],"next":0}`)
+	return []byte(sb.String())
+}
+
+func TestDecodeFilelistMatchesUnmarshal(t *testing.T) {
+	bodies := []string{
+		rrf3Filelist,
+		string(syntheticFilelist(100)),
+		`{"dir":"0:/gcodes","first":100,"files":[],"next":0}`,
+		`{"dir":"0:/gcodes","first":0,"files":null,"next":0}`,
+		`{"err":2}`,
+		`{"err":"1"}`,
+		`{"Dir":"0:/gcodes","FILES":[{"Type":"f","NAME":"a.g","size":1,"date":"2023-05-01T09:30:12"}],"Next":3}`,
+		`{"dir":"0:/","files":[],"next":0,"bogus":1}`,
+		`{"dir":"0:/","files":[{"type":"f","name":"a","bogus":1}],"next":0}`,
+		`{"dir":"0:/","files":[],"next":0} {}`,
+		`{"dir":"0:/","files":{},"next":0}`,
+	}
+	for _, strict := range []bool{false, true} {
+		r := New("duet.local", 80, false, WithStrictDecoding(strict))
+		for _, body := range bodies {
+			var want, got Filelist
+			werr := r.decode([]byte(body), &want)
+			gerr := r.decodeFilelist(strings.NewReader(body), &got)
+			if (werr == nil) != (gerr == nil) {
+				t.Errorf("strict=%t %s: decodeFilelist err = %v, decode err = %v", strict, body, gerr, werr)
+				continue
+			}
+			if werr != nil {
+				continue
+			}
+			if got.Dir != want.Dir || got.First != want.First || got.Next != want.Next || got.Err != want.Err || !reflect.DeepEqual(got.Files, want.Files) {
+				t.Errorf("strict=%t %s: decodeFilelist = %+v, decode = %+v", strict, body, &got, &want)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeFilelist(b *testing.B) {
+	body := syntheticFilelist(50000)
+	r := New("duet.local", 80, false)
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var fl Filelist
+			if err := r.decodeFilelist(bytes.NewReader(body), &fl); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			var fl Filelist
+			if err := json.Unmarshal(body, &fl); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
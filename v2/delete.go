@@ -0,0 +1,141 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeleteRecursive removes the given path including all of its contents. On firmware
+// supporting it (RRF 3.5 and later) this is done in a single request, otherwise the
+// tree is listed and deleted bottom-up one entry at a time.
+func (r *RRFFileManager) DeleteRecursive(ctx context.Context, path string) error {
+	return r.DeleteRecursiveWithProgress(ctx, path, nil)
+}
+
+// DeleteRecursiveWithProgress removes the given path including all of its contents
+// like DeleteRecursive and calls progress after each deleted entry if it is not nil.
+// On firmware deleting the whole tree in a single request progress is only called
+// once for path. If ctx is done before all entries are deleted a *TraversalError is
+// returned whose Completed field holds the number of entries deleted so far.
+func (r *RRFFileManager) DeleteRecursiveWithProgress(ctx context.Context, path string, progress func(path string)) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	path = cleanPath(path)
+	if err := r.checkInUse(ctx, path); err != nil {
+		return err
+	}
+	defer r.fileinfoCache.invalidate(path)
+	d := &treeDeleter{r: r, progress: progress}
+	if ok, err := r.firmwareAtLeast(ctx, 3, 5); err == nil && ok {
+		vals := r.query("name", path, "recursive", "yes")
+		resp, _, err := r.doJSONRequest(ctx, fmt.Sprintf(deleteURL, r.baseURL, vals))
+		if err := r.writeError(ctx, path, r.checkError(fmt.Sprintf("Delete %s recursively", path), resp, err)); err != nil {
+			return err
+		}
+		d.done(path)
+		return nil
+	}
+
+	fl, err := r.Filelist(ctx, path, true)
+	if err == ErrDirectoryNotFound {
+
+		// Not a directory so there is nothing to recurse into
+		if err := r.deletePath(ctx, path); err != nil {
+			return err
+		}
+		d.done(path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return d.deleteTree(ctx, fl)
+}
+
+// treeDeleter deletes a tree bottom-up counting the deleted entries
+type treeDeleter struct {
+	r        *RRFFileManager
+	progress func(path string)
+	deleted  int
+}
+
+// deleteTree deletes all files and subdirectories of fl before deleting fl itself
+func (d *treeDeleter) deleteTree(ctx context.Context, fl *Filelist) error {
+	for _, subdir := range fl.Subdirs {
+		if err := d.deleteTree(ctx, subdir); err != nil {
+			return err
+		}
+	}
+	for _, f := range fl.Files {
+		if !f.IsFile() {
+			continue
+		}
+		if err := d.deleteStep(ctx, JoinPath(fl.Dir, f.Name)); err != nil {
+			return err
+		}
+	}
+	return d.deleteStep(ctx, fl.Dir)
+}
+
+// deleteStep deletes a single path as part of a recursive deletion checking
+// for ctx being done before
+func (d *treeDeleter) deleteStep(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return &TraversalError{Path: path, Err: err, Completed: d.deleted}
+	}
+	err := traversalError(ctx, path, d.r.deletePath(ctx, cleanPath(path)))
+	var terr *TraversalError
+	if errors.As(err, &terr) {
+		terr.Completed = d.deleted
+	}
+	if err != nil {
+		return err
+	}
+	d.done(path)
+	return nil
+}
+
+// done records path as deleted
+func (d *treeDeleter) done(path string) {
+	d.deleted++
+	if d.progress != nil {
+		d.progress(path)
+	}
+}
+
+// DeleteIfExists removes the given path like Delete but does not fail if there
+// is no such file or directory. Other errors are returned as usual.
+func (r *RRFFileManager) DeleteIfExists(ctx context.Context, path string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	path = cleanPath(path)
+	if err := r.checkInUse(ctx, path); err != nil {
+		return err
+	}
+	defer r.fileinfoCache.invalidate(path)
+	err := r.deleteRequest(ctx, path)
+	var rerr *ResponseError
+	if !errors.As(err, &rerr) {
+		return err
+	}
+
+	// RRF does not report missing files with a distinct error code so check for it.
+	// Listing the parent directory also reveals a volume that is not mounted.
+	exists, eerr := r.Exists(ctx, path)
+	if eerr == ErrDriveNotMounted {
+		return eerr
+	}
+	if eerr == nil && !exists {
+		return nil
+	}
+	return r.writeError(ctx, path, err)
+}
@@ -0,0 +1,94 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Standard directories of RRF on the first volume. Use OnVolume to get them for
+// another volume.
+const (
+	// GCodesDir holds the job files
+	GCodesDir = "0:/gcodes"
+	// MacrosDir holds the macro files
+	MacrosDir = "0:/macros"
+	// SysDir holds the configuration files like config.g
+	SysDir = "0:/sys"
+	// FilamentsDir holds one subdirectory per filament profile
+	FilamentsDir = "0:/filaments"
+	// MenuDir holds the menu files of 12864 displays
+	MenuDir = "0:/menu"
+)
+
+// OnVolume returns dir moved to the given volume, e.g. OnVolume(GCodesDir, 1)
+// returns "1:/gcodes". A dir without volume specifier is treated as being on the
+// first volume.
+func OnVolume(dir string, volume int) string {
+	_, rest := splitVolume(cleanPath(dir))
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return strconv.Itoa(volume) + ":" + rest
+}
+
+// Directories are the directories configured on the board, e.g. with M505 for the
+// system directory
+type Directories struct {
+	Filaments string
+	Firmware  string
+	GCodes    string
+	Macros    string
+	Menu      string
+	Scans     string
+	System    string
+	Web       string
+}
+
+// defaultDirectories are the directories used by RRF unless configured otherwise
+var defaultDirectories = Directories{
+	Filaments: FilamentsDir,
+	Firmware:  "0:/firmware",
+	GCodes:    GCodesDir,
+	Macros:    MacrosDir,
+	Menu:      MenuDir,
+	Scans:     "0:/scans",
+	System:    SysDir,
+	Web:       "0:/www",
+}
+
+// Directories returns the directories configured on the board as reported by the
+// object model. On firmware without object model the standard directories are
+// returned. Directories the board does not report keep their standard value.
+func (r *RRFFileManager) Directories(ctx context.Context) (*Directories, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	d := defaultDirectories
+	err := r.getModel(ctx, "directories", "", &d)
+	var serr *StatusError
+	if errors.Is(err, ErrNoObjectModel) || (errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound) {
+		d = defaultDirectories
+		return &d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// directories returns the directories configured on the board falling back to the
+// standard directories if they cannot be discovered
+func (r *RRFFileManager) directories(ctx context.Context) *Directories {
+	d, err := r.Directories(ctx)
+	if err != nil {
+		if r.debug {
+			log.Printf("Failed to read configured directories, using defaults: %s", err)
+		}
+		d = &Directories{}
+		*d = defaultDirectories
+	}
+	return d
+}
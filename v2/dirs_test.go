@@ -0,0 +1,55 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestConfiguredDirectories(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     func(w http.ResponseWriter)
+		heightmap string
+		filaments string
+	}{
+		{"configured", func(w http.ResponseWriter) {
+			fmt.Fprint(w, `{"key":"directories","flags":"","result":{"filaments":"1:/filaments/","system":"0:/sys-custom/"}}`)
+		}, "0:/sys-custom/heightmap.csv", "1:/filaments"},
+		{"no object model", func(w http.ResponseWriter) {
+			http.NotFound(w, nil)
+		}, "0:/sys/heightmap.csv", "0:/filaments"},
+		{"discovery fails", func(w http.ResponseWriter) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}, "0:/sys/heightmap.csv", "0:/filaments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var downloaded, listed string
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/rr_model":
+					tt.model(w)
+				case "/rr_download":
+					downloaded = req.URL.Query().Get("name")
+					http.NotFound(w, req)
+				case "/rr_filelist":
+					listed = req.URL.Query().Get("dir")
+					fmt.Fprintf(w, `{"dir":%q,"first":0,"files":[],"next":0}`, listed)
+				}
+			})
+			ctx := context.Background()
+			r.DownloadHeightmap(ctx)
+			if downloaded != tt.heightmap {
+				t.Errorf("downloaded height map from %q, want %q", downloaded, tt.heightmap)
+			}
+			if _, err := r.Filaments(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if listed != tt.filaments {
+				t.Errorf("listed filaments in %q, want %q", listed, tt.filaments)
+			}
+		})
+	}
+}
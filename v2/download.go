@@ -0,0 +1,156 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrSizeMismatch is the error returned if a downloaded file does not have
+// the size reported by the board
+var ErrSizeMismatch = errors.New("Downloaded size does not match")
+
+// DownloadToFile downloads the file at remotePath and writes it to localPath
+// creating missing parent directories. It returns the duration of the download.
+func (r *RRFFileManager) DownloadToFile(ctx context.Context, remotePath, localPath string) (*time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	remotePath = r.resolvePath(remotePath)
+	body, duration, err := r.Download(ctx, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(localPath, body, 0644); err != nil {
+		return nil, err
+	}
+	return duration, nil
+}
+
+// DownloadIfModified downloads the file at path only if it was modified after since.
+// The modification time is checked with Fileinfo first. If the file has not changed
+// it returns (nil, false, nil).
+func (r *RRFFileManager) DownloadIfModified(ctx context.Context, path string, since time.Time) ([]byte, bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	info, err := r.Fileinfo(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.LastModified().After(since) {
+		return nil, false, nil
+	}
+	body, _, err := r.Download(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
+
+// DownloadToFileResume downloads the file at remotePath to localPath. If localPath
+// already exists as the partial result of an interrupted download only the missing
+// remainder is requested using a Range header and appended. The response is written
+// to localPath while it is received so an interrupted call can be resumed by calling
+// it again. The final size is verified against the size reported by Fileinfo. If the
+// board does not honor the Range header the whole file is downloaded again.
+func (r *RRFFileManager) DownloadToFileResume(ctx context.Context, remotePath, localPath string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	remotePath = r.resolvePath(remotePath)
+	info, err := r.Fileinfo(ctx, remotePath)
+	if err != nil {
+		return err
+	}
+
+	var offset int64
+	if st, err := os.Stat(localPath); err == nil {
+		offset = st.Size()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if uint64(offset) > info.Size {
+
+		// Local file is not a prefix of the remote one so start from scratch
+		offset = 0
+	}
+
+	if uint64(offset) < info.Size {
+		if err := r.downloadFrom(ctx, remotePath, localPath, offset); err != nil {
+			return err
+		}
+	}
+
+	st, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if uint64(st.Size()) != info.Size {
+		return ErrSizeMismatch
+	}
+	return nil
+}
+
+// downloadFrom requests the file at remotePath starting at offset and streams it
+// to localPath. What is received is kept in localPath even if the transfer fails.
+func (r *RRFFileManager) downloadFrom(ctx context.Context, remotePath, localPath string, offset int64) error {
+	vals := r.query("name", cleanPath(remotePath))
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	var n int64
+	_, err := r.roundTrip(ctx, http.MethodGet, fmt.Sprintf(downloadURL, r.baseURL, vals), nil, header, 0, func(statusCode int, body io.Reader) error {
+		if statusCode != http.StatusPartialContent {
+
+			// The board ignored the Range header and sends the full file
+			offset = 0
+		}
+		f, err := openAt(localPath, offset)
+		if err != nil {
+			return err
+		}
+		n, err = io.Copy(f, body)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+	r.stats.addDownload(n)
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+		err = ErrFileNotFound
+	}
+	if err != nil {
+		return r.mountError(ctx, remotePath, err)
+	}
+	return nil
+}
+
+// openAt opens the file at p for writing at offset truncating anything after it
+// and creates it along with missing parent directories if necessary
+func openAt(p string, offset int64) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,99 @@
+package librfm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDownloadToFileResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	cut := 50000
+	var ranges []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_fileinfo":
+			fmt.Fprintf(w, `{"err":0,"size":%d,"lastModified":"2024-06-01T12:00:00"}`, len(content))
+		case "/rr_download":
+			rng := req.Header.Get("Range")
+			ranges = append(ranges, rng)
+			if rng == "" {
+
+				// Announce the full file but drop the connection halfway through
+				conn, buf, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n", len(content))
+				buf.Write(content[:cut])
+				buf.Flush()
+				conn.Close()
+				return
+			}
+			offset, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil {
+				t.Errorf("Range = %q", rng)
+				return
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(content[offset:])
+		}
+	})
+	ctx := context.Background()
+	local := filepath.Join(t.TempDir(), "gcodes", "a.g")
+
+	if err := r.DownloadToFileResume(ctx, "0:/gcodes/a.g", local); err == nil {
+		t.Fatal("interrupted download succeeded")
+	}
+	st, err := os.Stat(local)
+	if err != nil {
+		t.Fatalf("nothing kept to resume from: %v", err)
+	}
+	if st.Size() != int64(cut) {
+		t.Fatalf("kept %d bytes, want %d", st.Size(), cut)
+	}
+
+	if err := r.DownloadToFileResume(ctx, "0:/gcodes/a.g", local); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	got, err := os.ReadFile(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("resumed file differs from remote content")
+	}
+	if want := []string{"", fmt.Sprintf("bytes=%d-", cut)}; fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Errorf("Range headers = %q, want %q", ranges, want)
+	}
+}
+
+func TestDownloadToFileResumeIgnoredRange(t *testing.T) {
+	content := []byte("complete content")
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_fileinfo":
+			fmt.Fprintf(w, `{"err":0,"size":%d,"lastModified":"2024-06-01T12:00:00"}`, len(content))
+		case "/rr_download":
+			w.Write(content)
+		}
+	})
+	local := filepath.Join(t.TempDir(), "a.g")
+	if err := os.WriteFile(local, []byte("compl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DownloadToFileResume(context.Background(), "0:/a.g", local); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, content) {
+		t.Errorf("file = %q, want %q", got, content)
+	}
+}
@@ -0,0 +1,54 @@
+package librfm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// FindDuplicates searches dir recursively for files with identical content. Files
+// are grouped by size first and only files sharing their size with another one are
+// downloaded to compare their SHA256 sums. The result maps the hex encoded sum to
+// the paths of all files having this content. Empty files are not considered.
+func (r *RRFFileManager) FindDuplicates(ctx context.Context, dir string) (map[string][]string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	fl, err := r.Filelist(ctx, dir, true)
+	if err != nil {
+		return nil, err
+	}
+	bySize := make(map[uint64][]string)
+	err = fl.Walk(func(dir string, f File) error {
+		if !f.IsDir() && f.Size > 0 {
+			bySize[f.Size] = append(bySize[f.Size], JoinPath(dir, f.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byHash := make(map[string][]string)
+	for _, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, p := range paths {
+			b, _, err := r.Download(ctx, p)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(b)
+			h := hex.EncodeToString(sum[:])
+			byHash[h] = append(byHash[h], p)
+		}
+	}
+	dups := make(map[string][]string)
+	for h, paths := range byHash {
+		if len(paths) > 1 {
+			dups[h] = paths
+		}
+	}
+	return dups, nil
+}
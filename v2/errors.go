@@ -0,0 +1,122 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StatusError is the error returned if the board answered a request with a
+// non-2xx HTTP status code
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// RetryAfter is the delay the board asked for with a Retry-After header
+	// or zero if there was none
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected HTTP status: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary returns true for server errors (5xx) and 429 Too Many Requests
+// which are worth retrying
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// ContentTypeError is the error returned if a response expected to be JSON declared
+// a different content type, e.g. because a proxy served an HTML page instead
+type ContentTypeError struct {
+	// ContentType is the value of the response's Content-Type header
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("Unexpected content type of response: %s", e.ContentType)
+}
+
+// TransportError is the error returned if a request could not be sent or its
+// response could not be read, e.g. because the connection dropped
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary returns true unless the request was cancelled on purpose
+func (e *TransportError) Temporary() bool {
+	return !errors.Is(e.Err, context.Canceled)
+}
+
+// ResponseError is the error returned if the board reported a failure through the
+// err field of its response. These are permanent application errors.
+type ResponseError struct {
+	// Action describes the operation that failed
+	Action string
+	// Code is the numeric error code reported by the board
+	Code ErrorCode
+	// Message is the human readable description sent by the board if any
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Failed to perform: %s: %s", e.Action, e.Message)
+	}
+	return fmt.Sprintf("Failed to perform: %s", e.Action)
+}
+
+// Temporary always returns false since retrying will not change the outcome
+func (e *ResponseError) Temporary() bool {
+	return false
+}
+
+// IsTemporary reports whether err is a transient failure like a dropped connection
+// or a server error that might succeed when retried. Errors reported by the board
+// itself, e.g. a missing file, are permanent.
+func IsTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// TraversalError is the error returned if ctx of a recursive operation was
+// cancelled or its deadline exceeded. Path is where the operation stopped.
+// Completed is the number of entries processed before, e.g. deleted by
+// DeleteRecursiveWithProgress, and zero for operations not counting them.
+type TraversalError struct {
+	Path      string
+	Err       error
+	Completed int
+}
+
+func (e *TraversalError) Error() string {
+	if e.Completed > 0 {
+		return fmt.Sprintf("%s during traversal of %s after %d entries", e.Err, e.Path, e.Completed)
+	}
+	return fmt.Sprintf("%s during traversal of %s", e.Err, e.Path)
+}
+
+func (e *TraversalError) Unwrap() error {
+	return e.Err
+}
+
+// traversalError wraps err into a TraversalError for path if it was caused by
+// ctx being done and it is not already a TraversalError for a deeper path
+func traversalError(ctx context.Context, path string, err error) error {
+	var terr *TraversalError
+	if err == nil || ctx.Err() == nil || errors.As(err, &terr) {
+		return err
+	}
+	return &TraversalError{Path: path, Err: ctx.Err()}
+}
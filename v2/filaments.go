@@ -0,0 +1,41 @@
+package librfm
+
+import (
+	"context"
+	"strings"
+)
+
+// Filaments returns the names of all filament profiles configured on the board,
+// i.e. the names of the subdirectories of the configured filaments directory. A
+// board without that directory has no filaments and an empty list is returned.
+func (r *RRFFileManager) Filaments(ctx context.Context) ([]string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dirs, err := r.ListDirs(ctx, r.directories(ctx).Filaments)
+	if err == ErrDirectoryNotFound {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		names = append(names, d.Name)
+	}
+	return names, nil
+}
+
+// FilamentConfig downloads the given file of the named filament profile. If file is
+// empty the profile's config.g that RRF runs when loading the filament is fetched.
+func (r *RRFFileManager) FilamentConfig(ctx context.Context, name, file string) ([]byte, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return nil, ErrInvalidName
+	}
+	if file == "" {
+		file = "config.g"
+	}
+	b, _, err := r.Download(ctx, JoinPath(r.directories(ctx).Filaments, name, file))
+	return b, err
+}
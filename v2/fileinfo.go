@@ -1,7 +1,10 @@
 package librfm
 
 import (
+	"encoding/json"
 	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,10 +12,14 @@ import (
 // the rr_fileinfo interface was successful but returned err != 0
 var ErrFileNotFound = errors.New("File not found")
 
-// Fileinfo is the structure returned at rr_fileinfo interface
+// Fileinfo is the structure returned at rr_fileinfo interface. Only Size and the
+// modification time are available for every file. The job specific fields (Height,
+// FirstLayerHeight, LayerHeight, PrintTime, Filament and GeneratedBy) are only
+// populated for job files generated by a slicer and keep their zero values for
+// other files like macros or configuration files.
 type Fileinfo struct {
 	// Err holds a numeric error code where 0 means no error
-	Err uint64
+	Err ErrorCode
 	// Size is the size of a file in bytes (0 for directories)
 	Size      uint64
 	Timestamp localTime `json:"lastModified"`
@@ -25,12 +32,71 @@ type Fileinfo struct {
 	// PrintTime in seconds for a job file
 	PrintTime uint64
 	// Filament contains an array of used filaments in mm
-	Filament []float64
+	Filament FilamentLengths
 	// GeneratedBy returns the string which application created the job file
 	GeneratedBy string
+	// FileName is the full path of the file as reported by RRF 3 and later
+	FileName string
+	// NumLayers is the number of layers of a job file if the slicer reported it
+	NumLayers uint64
+	// SimulatedTime in seconds if the job file was simulated before
+	SimulatedTime uint64
+	// PrintDuration in seconds is only reported for the file currently printed
+	PrintDuration float64
+	// Thumbnails describes the thumbnails embedded in a job file by the slicer
+	Thumbnails []Thumbnail
+}
+
+// Thumbnail describes a thumbnail image embedded in a job file
+type Thumbnail struct {
+	// Width and Height of the image in pixels
+	Width  uint64
+	Height uint64
+	// Format of the image, e.g. "qoi" or "png"
+	Format string `json:"fmt"`
+	// Offset of the thumbnail data in the job file in bytes
+	Offset uint64
+	// Size of the encoded thumbnail data in bytes
+	Size uint64
 }
 
 // LastModified returns the last modification time of this file
 func (f *Fileinfo) LastModified() time.Time {
 	return f.Timestamp.Time
 }
+
+// FilamentLengths holds the filament usage per extruder in mm
+type FilamentLengths []float64
+
+// UnmarshalJSON decodes filament usage given as JSON array, as single number or as
+// string of comma separated numbers like some slicers write for single extruder jobs
+func (f *FilamentLengths) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	switch {
+	case s == "null":
+		*f = nil
+		return nil
+	case strings.HasPrefix(s, "["):
+		var l []float64
+		if err := json.Unmarshal(b, &l); err != nil {
+			return err
+		}
+		*f = l
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	l := make(FilamentLengths, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		l = append(l, n)
+	}
+	*f = l
+	return nil
+}
@@ -2,21 +2,41 @@ package librfm
 
 import (
 	"errors"
-	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
 type localTime struct {
 	Time time.Time
+	// raw keeps the timestamp as sent so it can be parsed again in another
+	// location without being shifted by the DST rules of time.Local
+	raw string
 }
 
 func (lt *localTime) UnmarshalJSON(b []byte) (err error) {
+	// Some responses omit the timestamp by sending null or an empty string
+	if s := string(b); s == "null" || s == `""` {
+		lt.Time, lt.raw = time.Time{}, ""
+		return nil
+	}
+
 	// Parse date string in local time (it does not provide any timezone information)
-	lt.Time, err = time.ParseInLocation(`"`+TimeFormat+`"`, string(b), time.Local)
+	lt.raw = string(b)
+	lt.Time, err = time.ParseInLocation(`"`+TimeFormat+`"`, lt.raw, time.Local)
 	return err
 }
 
+// in parses the timestamp as sent by the board in loc
+func (lt *localTime) in(loc *time.Location) {
+	if lt.raw == "" || loc == time.Local {
+		return
+	}
+	if t, err := time.ParseInLocation(`"`+TimeFormat+`"`, lt.raw, loc); err == nil {
+		lt.Time = t
+	}
+}
+
 // File resembles the JSON object returned in the files property of the rr_filelist response
 type File struct {
 	// Type of file - can be file or directory
@@ -50,39 +70,139 @@ var ErrDirectoryNotFound = errors.New("Directory not found")
 // ErrDriveNotMounted is the error returned if the requested drive is not mounted
 var ErrDriveNotMounted = errors.New("Drive not mounted")
 
+// SortOrder defines how the entries of a Filelist are sorted
+type SortOrder int
+
+const (
+	// SortDirsFirst sorts directories before files and both by name (the default)
+	SortDirsFirst SortOrder = iota
+	// SortByName sorts all entries by name regardless of their type
+	SortByName
+	// SortByDate sorts all entries by modification date, newest first, and by name
+	SortByDate
+)
+
+// FilelistOptions control how a Filelist is fetched
+type FilelistOptions struct {
+	// Recursive also fetches the listings of all subdirectories into Subdirs
+	Recursive bool
+	// Sort is the order of the entries of each listing
+	Sort SortOrder
+	// PreserveOrder skips sorting and keeps the entries in the order RRF returned
+	// them. Listings split into several pages are concatenated in fetch order.
+	PreserveOrder bool
+}
+
+// sortFiles sorts files in place according to order
+func sortFiles(files []File, order SortOrder) {
+	sort.SliceStable(files, func(i, j int) bool {
+		switch order {
+		case SortByName:
+			return files[i].Name < files[j].Name
+		case SortByDate:
+			if !files[i].Date().Equal(files[j].Date()) {
+				return files[i].Date().After(files[j].Date())
+			}
+			return files[i].Name < files[j].Name
+		}
+
+		// Both same type so compare by name
+		if files[i].Type == files[j].Type {
+			return files[i].Name < files[j].Name
+		}
+
+		// Different types -> sort folders first
+		return files[i].Type == typeDirectory
+	})
+}
+
 // Filelist resembled the JSON object in rr_filelist
 type Filelist struct {
 	Dir     string
 	Files   []File
+	First   uint64
 	Next    uint64
-	Err     uint64
+	Err     ErrorCode
 	Subdirs []*Filelist
 	once    sync.Once
-	index   map[string]bool
+	index   map[string]File
+
+	// indexDirs also indexes directory entries whose listing was not fetched
+	indexDirs bool
 }
 
-// Contains checks for a path to exist in this filelist
+// Contains checks for a path to exist in this filelist. A trailing slash on
+// directory paths is ignored. Directories are found if their own listing is part
+// of the Filelist, i.e. its Dir and all Subdirs of a recursive listing. Directory
+// entries of a non-recursive listing are only found if the manager was created
+// WithDirectoriesInIndex. Files are always found.
 func (f *Filelist) Contains(path string) bool {
+	_, ok := f.Lookup(path)
+	return ok
+}
+
+// Lookup returns the File entry for path if it exists in this filelist. Paths are
+// normalized so a trailing slash on a directory does not make a difference. For
+// the directory of the Filelist itself which has no entry of its own a File
+// with only Type and Name set is returned.
+func (f *Filelist) Lookup(path string) (File, bool) {
 	f.once.Do(f.buildIndex)
-	return f.index[path]
+	file, ok := f.index[cleanPath(path)]
+	return file, ok
 }
 
 func (f *Filelist) buildIndex() {
-	f.index = make(map[string]bool)
+	f.index = make(map[string]File)
 
-	// Init index of subdirs
-	for _, subdir := range f.Subdirs {
-		subdir.buildIndex()
-		for k, v := range subdir.index {
-			f.index[k] = v
+	// Entries of directories seen in a listing to be indexed once their subdir is visited
+	dirs := make(map[string]File)
+
+	// Traverse the tree iteratively so deeply nested directories do not grow the stack
+	// and subdirs do not have to build (and copy) indexes of their own
+	stack := []*Filelist{f}
+	for len(stack) > 0 {
+		fl := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		dir := cleanPath(fl.Dir)
+		if entry, ok := dirs[dir]; ok {
+			f.index[dir] = entry
+		} else {
+			_, name := SplitPath(dir)
+			f.index[dir] = File{Type: typeDirectory, Name: name}
+		}
+		for _, file := range fl.Files {
+			p := JoinPath(dir, file.Name)
+			if file.IsDir() {
+				dirs[p] = file
+				if !f.indexDirs {
+					continue
+				}
+			}
+			f.index[p] = file
 		}
-		f.index[subdir.Dir] = true
+		stack = append(stack, fl.Subdirs...)
 	}
+}
+
+// FullPath returns the full path of the entry f of this Filelist including the
+// volume prefix, e.g. "0:/gcodes/job.gcode"
+func (f *Filelist) FullPath(file File) string {
+	return JoinPath(cleanPath(f.Dir), file.Name)
+}
+
+// Walk calls fn for every entry of this Filelist and recursively for all its Subdirs.
+// dir is the directory containing file. If fn returns an error walking stops and this
+// error is returned.
+func (f *Filelist) Walk(fn func(dir string, file File) error) error {
 	for _, file := range f.Files {
-		if file.IsDir() {
-			continue
+		if err := fn(f.Dir, file); err != nil {
+			return err
+		}
+	}
+	for _, subdir := range f.Subdirs {
+		if err := subdir.Walk(fn); err != nil {
+			return err
 		}
-		f.index[fmt.Sprintf("%s/%s", f.Dir, file.Name)] = true
 	}
-	f.index[f.Dir] = true
+	return nil
 }
@@ -0,0 +1,192 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// setLocal replaces time.Local by the named location for the duration of the test
+func setLocal(t *testing.T, name string) {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = old })
+}
+
+func TestTimestampsDSTBoundary(t *testing.T) {
+	setLocal(t, "Europe/Berlin")
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		stamp string
+		loc   *time.Location
+		want  time.Time
+	}{
+		// 02:30 does not exist in Berlin on that day
+		{"local gap in UTC", "2024-03-31T02:30:00", time.UTC, time.Date(2024, 3, 31, 2, 30, 0, 0, time.UTC)},
+		// 02:30 exists twice in Berlin on that day
+		{"local overlap in UTC", "2024-10-27T02:30:00", time.UTC, time.Date(2024, 10, 27, 2, 30, 0, 0, time.UTC)},
+		{"local gap in New York", "2024-03-31T02:30:00", newYork, time.Date(2024, 3, 31, 2, 30, 0, 0, newYork)},
+		{"board gap in New York", "2024-03-10T02:30:00", newYork, time.Date(2024, 3, 10, 2, 30, 0, 0, newYork)},
+		{"ordinary", "2024-06-01T12:00:00", time.UTC, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				switch req.URL.Path {
+				case "/rr_filelist":
+					fmt.Fprintf(w, `{"dir":"0:/gcodes","first":0,"files":[{"type":"f","name":"a.g","size":1,"date":%q}],"next":0}`, tt.stamp)
+				case "/rr_fileinfo":
+					fmt.Fprintf(w, `{"err":0,"size":1,"lastModified":%q}`, tt.stamp)
+				}
+			}, WithLocation(tt.loc))
+
+			fl, err := r.Filelist(context.Background(), "0:/gcodes", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fl.Files[0].Date(); !got.Equal(tt.want) {
+				t.Errorf("Filelist date = %v, want %v", got, tt.want)
+			}
+
+			fi, err := r.Fileinfo(context.Background(), "0:/gcodes/a.g")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fi.LastModified(); !got.Equal(tt.want) {
+				t.Errorf("Fileinfo date = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadTimestampDSTBoundary(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := now
+	t.Cleanup(func() { now = old })
+
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"before spring forward", time.Date(2024, 3, 31, 0, 59, 59, 0, time.UTC), berlin, "2024-03-31T01:59:59"},
+		{"after spring forward", time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), berlin, "2024-03-31T03:00:00"},
+		{"before fall back", time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), berlin, "2024-10-27T02:30:00"},
+		{"after fall back", time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), berlin, "2024-10-27T02:30:00"},
+		{"UTC", time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC), time.UTC, "2024-03-31T01:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+				got = req.URL.Query().Get("time")
+				fmt.Fprint(w, `{"err":0}`)
+			}, WithLocation(tt.loc))
+			now = func() time.Time { return tt.now }
+
+			if _, err := r.Upload(context.Background(), "0:/gcodes/a.g", strings.NewReader("G28")); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("time = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilelistDriveRemovedOnLaterPage(t *testing.T) {
+	var pages []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		first := req.URL.Query().Get("first")
+		pages = append(pages, first)
+		switch first {
+		case "0":
+			fmt.Fprint(w, `{"dir":"0:/gcodes","first":0,"files":[{"type":"f","name":"a.g","size":1,"date":"2024-06-01T12:00:00"}],"next":1}`)
+		default:
+			fmt.Fprint(w, `{"err":1}`)
+		}
+	})
+
+	fl, err := r.Filelist(context.Background(), "0:/gcodes", false)
+	if err != ErrDriveNotMounted {
+		t.Fatalf("Filelist = %v, %v; want ErrDriveNotMounted", fl, err)
+	}
+	if want := []string{"0", "1"}; fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("requested pages %q, want %q", pages, want)
+	}
+	select {
+	case <-r.Done():
+	default:
+		t.Error("Done() not closed after the drive was removed")
+	}
+}
+
+// deepTree returns a listing of dirs directories each nested in the previous one
+// and containing files files
+func deepTree(dirs, files int) *Filelist {
+	root := &Filelist{Dir: "0:/gcodes"}
+	fl := root
+	for i := 0; i < dirs; i++ {
+		name := fmt.Sprintf("d%d", i)
+		sub := &Filelist{Dir: fl.Dir + "/" + name}
+		fl.Files = append(fl.Files, File{Type: typeDirectory, Name: name})
+		for j := 0; j < files; j++ {
+			fl.Files = append(fl.Files, File{Type: typeFile, Name: fmt.Sprintf("f%d.g", j)})
+		}
+		fl.Subdirs = []*Filelist{sub}
+		fl = sub
+	}
+	return root
+}
+
+// wideTree returns a listing of dirs directories next to each other each
+// containing files files
+func wideTree(dirs, files int) *Filelist {
+	root := &Filelist{Dir: "0:/gcodes"}
+	for i := 0; i < dirs; i++ {
+		name := fmt.Sprintf("d%d", i)
+		sub := &Filelist{Dir: "0:/gcodes/" + name}
+		for j := 0; j < files; j++ {
+			sub.Files = append(sub.Files, File{Type: typeFile, Name: fmt.Sprintf("f%d.g", j)})
+		}
+		root.Files = append(root.Files, File{Type: typeDirectory, Name: name})
+		root.Subdirs = append(root.Subdirs, sub)
+	}
+	return root
+}
+
+func BenchmarkBuildIndex(b *testing.B) {
+	trees := []struct {
+		name string
+		fl   *Filelist
+	}{
+		{"deep", deepTree(1000, 10)},
+		{"wide", wideTree(100, 100)},
+	}
+	for _, tt := range trees {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tt.fl.buildIndex()
+			}
+		})
+	}
+}
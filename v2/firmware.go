@@ -0,0 +1,98 @@
+package librfm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type configResponse struct {
+	FirmwareVersion string
+}
+
+// FirmwareVersion returns the version of RepRapFirmware running on the board. It is
+// read from the object model and falls back to rr_config for older firmware. The
+// result is cached until the next call to Connect.
+func (r *RRFFileManager) FirmwareVersion(ctx context.Context) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	// The lock is not held during the requests so a slow board does not block Connect
+	r.fwMu.Lock()
+	version, gen := r.fwVersion, r.fwGen
+	r.fwMu.Unlock()
+	if version != "" {
+		return version, nil
+	}
+
+	err := r.getModel(ctx, "boards[0].firmwareVersion", "", &version)
+	if err != nil || version == "" {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(configURL, r.baseURL))
+		if err != nil {
+			return "", err
+		}
+		var c configResponse
+		if err := json.Unmarshal(body, &c); err != nil {
+			return "", err
+		}
+		if c.FirmwareVersion == "" {
+			return "", ErrNoObjectModel
+		}
+		version = c.FirmwareVersion
+	}
+
+	// A Connect in the meantime may have reached a different firmware
+	r.fwMu.Lock()
+	if r.fwGen == gen {
+		r.fwVersion = version
+	}
+	r.fwMu.Unlock()
+	return version, nil
+}
+
+// SupportsSessionKeys returns true if the firmware on the board supports
+// session keys for authentication which were added in RRF 3.5
+func (r *RRFFileManager) SupportsSessionKeys(ctx context.Context) (bool, error) {
+	return r.firmwareAtLeast(ctx, 3, 5)
+}
+
+// SupportsObjectModel returns true if the firmware on the board can be
+// queried through the object model which was added in RRF 3.0
+func (r *RRFFileManager) SupportsObjectModel(ctx context.Context) (bool, error) {
+	return r.firmwareAtLeast(ctx, 3, 0)
+}
+
+// firmwareAtLeast checks if the firmware version is at least major.minor
+func (r *RRFFileManager) firmwareAtLeast(ctx context.Context, major, minor int) (bool, error) {
+	version, err := r.FirmwareVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	ma, mi := parseVersion(version)
+	return ma > major || (ma == major && mi >= minor), nil
+}
+
+// parseVersion extracts major and minor version numbers from version strings
+// like "3.4.5", "3.5.0-rc.1" or "2.05.1"
+func parseVersion(version string) (major, minor int) {
+	parts := strings.SplitN(version, ".", 3)
+	major = leadingInt(parts[0])
+	if len(parts) > 1 {
+		minor = leadingInt(parts[1])
+	}
+	return major, minor
+}
+
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	i, _ := strconv.Atoi(s[:end])
+	return i
+}
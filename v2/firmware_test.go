@@ -0,0 +1,49 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSlowFirmwareVersionDoesNotBlockConnect(t *testing.T) {
+	queried := make(chan struct{})
+	release := make(chan struct{})
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_model":
+			close(queried)
+			<-release
+			fmt.Fprint(w, `{"key":"boards[0].firmwareVersion","flags":"","result":"3.4.5"}`)
+		case "/rr_connect":
+			fmt.Fprint(w, `{"err":0,"sessionTimeout":8000}`)
+		}
+	}, WithConnectInterval(0))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.FirmwareVersion(context.Background())
+		done <- err
+	}()
+	<-queried
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Connect(ctx, ""); err != nil {
+		t.Errorf("Connect while querying the firmware version: %v", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The version was requested before the Connect so it is not cached
+	r.fwMu.Lock()
+	cached := r.fwVersion
+	r.fwMu.Unlock()
+	if cached != "" {
+		t.Errorf("cached version %q from before Connect", cached)
+	}
+}
@@ -0,0 +1,85 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// replyTimeout bounds how long RunGCode waits for the board to process a code
+	replyTimeout = 2 * time.Second
+
+	// replyPollInterval is the delay between two checks for a reply to a code
+	replyPollInterval = 100 * time.Millisecond
+)
+
+// RunGCode sends the given G-code to the board and returns the reply it produced.
+// rr_gcode only queues the code so this waits up to 2s for the board to process
+// it. The reply is empty if the code did not produce one within that time.
+func (r *RRFFileManager) RunGCode(ctx context.Context, code string) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	seq, seqErr := r.replySeq(ctx)
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	vals := r.query("gcode", code)
+	resp, _, err := r.doJSONRequest(ctx, fmt.Sprintf(gcodeURL, r.baseURL, vals))
+	if err := r.checkError(fmt.Sprintf("G-code %s", code), resp, err); err != nil {
+		return "", err
+	}
+	deadline := time.Now().Add(replyTimeout)
+	if seqErr == nil {
+
+		// The sequence number of replies changes once the code produced one
+		for {
+			if err := sleepCtx(ctx, replyPollInterval); err != nil {
+				return "", err
+			}
+			s, err := r.replySeq(ctx)
+			if err != nil {
+				return "", err
+			}
+			if s != seq || !time.Now().Before(deadline) {
+				return r.fetchReply(ctx)
+			}
+		}
+	}
+
+	// Without object model the reply itself is polled
+	for {
+		reply, err := r.fetchReply(ctx)
+		if err != nil || reply != "" || !time.Now().Before(deadline) {
+			return reply, err
+		}
+		if err := sleepCtx(ctx, replyPollInterval); err != nil {
+			return "", err
+		}
+	}
+}
+
+// replySeq returns the sequence number of G-code replies from the object model
+func (r *RRFFileManager) replySeq(ctx context.Context) (uint64, error) {
+	var seq uint64
+	err := r.getModel(ctx, "seqs.reply", "", &seq)
+	return seq, err
+}
+
+// fetchReply returns the replies to G-codes the board has not yet handed out
+func (r *RRFFileManager) fetchReply(ctx context.Context) (string, error) {
+	reply, _, err := r.doGetRequest(ctx, fmt.Sprintf(replyURL, r.baseURL))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(reply)), nil
+}
+
+// gcodeError converts an error message in reply into an error for action
+func gcodeError(action, reply string) error {
+	if strings.HasPrefix(reply, "Error") {
+		return &ResponseError{Action: action, Message: reply}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockBoard processes G-codes only after its reply sequence number has been
+// polled a few times like a board that queues codes sent with rr_gcode
+type mockBoard struct {
+	mu       sync.Mutex
+	mounted  bool
+	busy     bool
+	pending  string
+	delay    int
+	seq      int
+	reply    string
+	received []string
+}
+
+// process runs the pending code and returns its reply
+func (b *mockBoard) process(code string) string {
+	b.received = append(b.received, code)
+	switch {
+	case strings.HasPrefix(code, "M21"):
+		b.mounted = true
+		return "SD card mounted in slot 0"
+	case strings.HasPrefix(code, "M22") && b.busy:
+		return "Error: M22: Cannot unmount SD card 0 because files are open on it"
+	case strings.HasPrefix(code, "M22"):
+		b.mounted = false
+		return "SD card 0 may now be removed"
+	case strings.HasPrefix(code, "M905"):
+		return "Error: M905: Invalid date"
+	}
+	return ""
+}
+
+func (b *mockBoard) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w.Header().Set("Content-Type", jsonContentType)
+	switch req.URL.Path {
+	case "/rr_gcode":
+		b.pending = req.URL.Query().Get("gcode")
+		b.delay = 2
+		fmt.Fprint(w, `{"buff":255}`)
+	case "/rr_reply":
+		fmt.Fprint(w, b.reply)
+		b.reply = ""
+	case "/rr_model":
+		switch req.URL.Query().Get("key") {
+		case "seqs.reply":
+			if b.pending != "" {
+				if b.delay--; b.delay < 0 {
+					if reply := b.process(b.pending); reply != "" {
+						b.reply = reply
+						b.seq++
+					}
+					b.pending = ""
+				}
+			}
+			fmt.Fprintf(w, `{"key":"seqs.reply","flags":"","result":%d}`, b.seq)
+		case "volumes[0]":
+			fmt.Fprintf(w, `{"key":"volumes[0]","flags":"","result":{"mounted":%t}}`, b.mounted)
+		}
+	}
+}
+
+func TestMountVolume(t *testing.T) {
+	ctx := context.Background()
+	b := &mockBoard{}
+	r := newTestManager(t, b.ServeHTTP)
+
+	if err := r.MountVolume(ctx, 0); err != nil {
+		t.Fatalf("MountVolume: %v", err)
+	}
+	if !b.mounted {
+		t.Error("volume not mounted")
+	}
+	if err := r.UnmountVolume(ctx, 0); err != nil {
+		t.Fatalf("UnmountVolume: %v", err)
+	}
+	if b.mounted {
+		t.Error("volume still mounted")
+	}
+
+	b.mounted, b.busy = true, true
+	if err := r.UnmountVolume(ctx, 0); err != ErrVolumeBusy {
+		t.Errorf("UnmountVolume of busy volume = %v, want ErrVolumeBusy", err)
+	}
+	if want := []string{"M21 P0", "M22 P0", "M22 P0"}; fmt.Sprint(b.received) != fmt.Sprint(want) {
+		t.Errorf("processed %q, want %q", b.received, want)
+	}
+}
+
+func TestSetBoardTimeRejected(t *testing.T) {
+	b := &mockBoard{}
+	r := newTestManager(t, b.ServeHTTP)
+
+	err := r.SetBoardTime(context.Background(), time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	var rerr *ResponseError
+	if !errors.As(err, &rerr) || !strings.Contains(rerr.Message, "Invalid date") {
+		t.Errorf("SetBoardTime = %v, want the board's error", err)
+	}
+}
+
+func TestRunGCodeWithoutObjectModel(t *testing.T) {
+	var polls int
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_gcode":
+			fmt.Fprint(w, `{"buff":255}`)
+		case "/rr_reply":
+			if polls++; polls > 2 {
+				fmt.Fprint(w, "ok\n")
+			}
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	reply, err := r.RunGCode(context.Background(), "M115")
+	if err != nil || reply != "ok" {
+		t.Errorf("RunGCode = %q, %v; want %q", reply, err, "ok")
+	}
+}
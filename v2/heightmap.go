@@ -0,0 +1,201 @@
+package librfm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heightmapFile is the name of the file in the system directory where RRF stores the
+// result of mesh bed probing
+const heightmapFile = "heightmap.csv"
+
+// ErrInvalidHeightmap is the error returned if a height map cannot be parsed or
+// does not match its meta data
+var ErrInvalidHeightmap = errors.New("Invalid height map")
+
+// HeightmapMeta describes the grid of a height map
+type HeightmapMeta struct {
+	// Axes are the letters of the two probed axes, usually X and Y
+	Axes [2]string
+
+	// Min and Max are the coordinates of the first and last probe point per axis
+	Min, Max [2]float64
+
+	// Radius is the probing radius on delta printers or -1 for a rectangular grid
+	Radius float64
+
+	// Spacing is the distance between two probe points per axis
+	Spacing [2]float64
+}
+
+// DownloadHeightmap downloads heightmap.csv from the configured system directory as
+// written by G29 and parses its grid. Each row of the returned grid is one line of
+// probe points along the first axis. Both the v2 format of RRF 2 and 3 as well as
+// the axis letter format of newer firmware are understood.
+func (r *RRFFileManager) DownloadHeightmap(ctx context.Context) ([][]float64, *HeightmapMeta, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	b, _, err := r.Download(ctx, JoinPath(r.directories(ctx).System, heightmapFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseHeightmap(b)
+}
+
+// UploadHeightmap serializes grid in RRF's height map format and uploads it as
+// heightmap.csv to the configured system directory from where it can be loaded
+// with G29 S1.
+func (r *RRFFileManager) UploadHeightmap(ctx context.Context, grid [][]float64, meta HeightmapMeta) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	b, err := formatHeightmap(grid, meta, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = r.Upload(ctx, JoinPath(r.directories(ctx).System, heightmapFile), bytes.NewReader(b))
+	return err
+}
+
+func parseHeightmap(b []byte) ([][]float64, *HeightmapMeta, error) {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	lines := make([]string, 0)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// First line is a comment with statistics, then column names and their values
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "RepRapFirmware height map file") {
+		return nil, nil, ErrInvalidHeightmap
+	}
+	names := splitCSV(lines[1])
+	values := splitCSV(lines[2])
+	if len(names) != len(values) {
+		return nil, nil, ErrInvalidHeightmap
+	}
+	cols := make(map[string]string, len(names))
+	for i, n := range names {
+		cols[n] = values[i]
+	}
+
+	meta := &HeightmapMeta{Axes: [2]string{"X", "Y"}}
+	prefix := [2]string{"x", "y"}
+	if _, ok := cols["axis0"]; ok {
+		meta.Axes = [2]string{cols["axis0"], cols["axis1"]}
+	}
+	var num [2]int
+	for i := 0; i < 2; i++ {
+		// Older files name columns like xmin, newer ones like min0
+		get := func(name string) (float64, error) {
+			v, ok := cols[prefix[i]+name]
+			if !ok {
+				v = cols[fmt.Sprintf("%s%d", name, i)]
+			}
+			return strconv.ParseFloat(v, 64)
+		}
+		var err error
+		if meta.Min[i], err = get("min"); err != nil {
+			return nil, nil, ErrInvalidHeightmap
+		}
+		if meta.Max[i], err = get("max"); err != nil {
+			return nil, nil, ErrInvalidHeightmap
+		}
+		if meta.Spacing[i], err = get("spacing"); err != nil {
+			return nil, nil, ErrInvalidHeightmap
+		}
+		n, err := get("num")
+		if err != nil || n < 1 {
+			return nil, nil, ErrInvalidHeightmap
+		}
+		num[i] = int(n)
+	}
+	var err error
+	if meta.Radius, err = strconv.ParseFloat(cols["radius"], 64); err != nil {
+		return nil, nil, ErrInvalidHeightmap
+	}
+
+	rows := lines[3:]
+	if len(rows) != num[1] {
+		return nil, nil, ErrInvalidHeightmap
+	}
+	grid := make([][]float64, 0, len(rows))
+	for _, line := range rows {
+		fields := splitCSV(line)
+		if len(fields) != num[0] {
+			return nil, nil, ErrInvalidHeightmap
+		}
+		row := make([]float64, 0, len(fields))
+		for _, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, nil, ErrInvalidHeightmap
+			}
+			row = append(row, v)
+		}
+		grid = append(grid, row)
+	}
+	return grid, meta, nil
+}
+
+// formatHeightmap writes grid in the v2 format that all RRF versions can load
+func formatHeightmap(grid [][]float64, meta HeightmapMeta, now time.Time) ([]byte, error) {
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		return nil, ErrInvalidHeightmap
+	}
+	var (
+		count              int
+		sum, sumSq         float64
+		minError, maxError = math.Inf(1), math.Inf(-1)
+	)
+	for _, row := range grid {
+		if len(row) != len(grid[0]) {
+			return nil, ErrInvalidHeightmap
+		}
+		for _, v := range row {
+			count++
+			sum += v
+			sumSq += v * v
+			minError = math.Min(minError, v)
+			maxError = math.Max(maxError, v)
+		}
+	}
+	mean := sum / float64(count)
+	deviation := math.Sqrt(math.Max(sumSq/float64(count)-mean*mean, 0))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "RepRapFirmware height map file v2 generated at %s, min error %.3f, max error %.3f, mean %.3f, deviation %.3f\n",
+		now.Format("2006-01-02 15:04"), minError, maxError, mean, deviation)
+	buf.WriteString("xmin,xmax,ymin,ymax,radius,xspacing,yspacing,xnum,ynum\n")
+	fmt.Fprintf(&buf, "%.2f,%.2f,%.2f,%.2f,%.2f,%.2f,%.2f,%d,%d\n",
+		meta.Min[0], meta.Max[0], meta.Min[1], meta.Max[1], meta.Radius,
+		meta.Spacing[0], meta.Spacing[1], len(grid[0]), len(grid))
+	for _, row := range grid {
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, "%7.3f", v)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func splitCSV(line string) []string {
+	fields := strings.Split(line, ",")
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
@@ -0,0 +1,60 @@
+package librfm
+
+import "context"
+
+// FileIterator yields the entries of a directory listing page by page as RRF sends
+// them, see FilelistIterator
+type FileIterator struct {
+	r     *RRFFileManager
+	ctx   context.Context
+	dir   string
+	files []File
+	pos   int
+	next  uint64
+	done  bool
+	err   error
+}
+
+// FilelistIterator returns an iterator over the entries of dir. Unlike Filelist,
+// which waits for all pages of a large listing, the next page is only requested
+// once all entries of the current one have been consumed, so a UI can show the
+// first entries right away. Entries are returned in the order RRF sends them
+// without sorting. After Next returned false Err reports whether the listing
+// ended because of an error.
+func (r *RRFFileManager) FilelistIterator(ctx context.Context, dir string) *FileIterator {
+	dir = r.resolvePath(dir)
+	it := &FileIterator{r: r, ctx: ctx, dir: cleanPath(dir)}
+	if err := checkPath(dir); err != nil {
+		it.err = err
+		it.done = true
+	}
+	return it
+}
+
+// Next returns the next entry of the listing and true or false if there are no
+// more entries or an error occurred
+func (it *FileIterator) Next() (File, bool) {
+	for it.pos >= len(it.files) {
+		if it.done {
+			return File{}, false
+		}
+		page, err := it.r.getFilelistPage(it.ctx, it.dir, it.next)
+		if err != nil {
+			it.err = err
+			it.done = true
+			return File{}, false
+		}
+		it.files, it.pos = page.Files, 0
+		it.next = page.Next
+		it.done = page.Next == 0
+	}
+	f := it.files[it.pos]
+	it.pos++
+	return f, true
+}
+
+// Err returns the error that ended the iteration or nil if the listing was
+// read completely
+func (it *FileIterator) Err() error {
+	return it.err
+}
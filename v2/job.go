@@ -0,0 +1,140 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNoJob is the error returned if no print job is running
+var ErrNoJob = errors.New("No job running")
+
+// jobModel resembles the job key of the object model
+type jobModel struct {
+	File struct {
+		FileName *string
+		Size     uint64
+	}
+	FilePosition uint64
+	TimesLeft    struct {
+		File     *float64
+		Filament *float64
+		Slicer   *float64
+	}
+}
+
+// Progress describes how far the currently running print job has advanced
+type Progress struct {
+	// File is the path of the job file being printed
+	File string
+	// Percent is the progress of the job based on the file position (0-100)
+	Percent float64
+	// FilePosition is the number of bytes of the job file processed so far
+	FilePosition uint64
+	// FileSize is the size of the job file in bytes
+	FileSize uint64
+	// TimeLeft is the estimated remaining time of the job or 0 if unknown. The
+	// estimate of the slicer is preferred over ones based on file or filament usage.
+	TimeLeft time.Duration
+}
+
+// getJob reads the job from the object model and returns ErrNoJob if none is running
+func (r *RRFFileManager) getJob(ctx context.Context) (*jobModel, error) {
+	var job jobModel
+	if err := r.getModel(ctx, "job", "", &job); err != nil {
+		return nil, err
+	}
+	if job.File.FileName == nil || *job.File.FileName == "" {
+		return nil, ErrNoJob
+	}
+	return &job, nil
+}
+
+// PrintProgress returns the progress of the currently running print job read
+// from the object model. It returns ErrNoJob if the board is not printing.
+func (r *RRFFileManager) PrintProgress(ctx context.Context) (*Progress, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	job, err := r.getJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Progress{
+		File:         *job.File.FileName,
+		FilePosition: job.FilePosition,
+		FileSize:     job.File.Size,
+	}
+	if p.FileSize > 0 {
+		p.Percent = float64(p.FilePosition) / float64(p.FileSize) * 100
+	}
+	for _, left := range []*float64{job.TimesLeft.Slicer, job.TimesLeft.File, job.TimesLeft.Filament} {
+		if left != nil && *left > 0 {
+			p.TimeLeft = time.Duration(*left * float64(time.Second))
+			break
+		}
+	}
+	return p, nil
+}
+
+// CurrentJob returns the path of the file the board is printing or an empty string
+// if it is idle. It is read from the object model and on firmware without object
+// model from the legacy rr_status endpoint.
+func (r *RRFFileManager) CurrentJob(ctx context.Context) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	job, err := r.getJob(ctx)
+	if err == ErrNoJob {
+		return "", nil
+	}
+	var serr *StatusError
+	if errors.Is(err, ErrNoObjectModel) || (errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound) {
+		s, err := r.Status(ctx, 3)
+		if err != nil {
+			return "", err
+		}
+		if s.File == "" {
+			return "", nil
+		}
+		return cleanPath(s.File), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return cleanPath(*job.File.FileName), nil
+}
+
+// ErrFileInUse is the error returned if a file that is being printed or a directory
+// containing it should be deleted or moved while WithActiveJobGuard is enabled
+var ErrFileInUse = errors.New("File in use by running job")
+
+// WithActiveJobGuard makes Delete, DeleteRecursive and Move refuse with ErrFileInUse
+// to touch the file being printed or a directory containing it. This costs an extra
+// request per operation and is therefore disabled by default but recommended for
+// interactive tools.
+func WithActiveJobGuard(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.jobGuard = enabled
+	}
+}
+
+// checkInUse returns ErrFileInUse if the guard is enabled and the cleaned path is
+// the file being printed or one of its parent directories
+func (r *RRFFileManager) checkInUse(ctx context.Context, path string) error {
+	if !r.jobGuard {
+		return nil
+	}
+	job, err := r.CurrentJob(ctx)
+	if err != nil || job == "" {
+		return err
+	}
+
+	// RRF reports the job with volume while path might lack it
+	job, path = OnVolume(job, volumeIndex(job)), OnVolume(path, volumeIndex(path))
+	if job == path || strings.HasPrefix(job, strings.TrimSuffix(path, "/")+"/") {
+		return ErrFileInUse
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// printingHandler answers like a board printing 0:/gcodes/sub/a.g and records
+// the paths of all delete and move requests
+func printingHandler(changed *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/rr_model":
+			fmt.Fprint(w, `{"key":"job","flags":"","result":{"file":{"fileName":"0:/gcodes/sub/a.g","size":100},"filePosition":10}}`)
+		case "/rr_delete":
+			*changed = append(*changed, req.URL.Query().Get("name"))
+			fmt.Fprint(w, `{"err":0}`)
+		case "/rr_move":
+			*changed = append(*changed, req.URL.Query().Get("old"))
+			fmt.Fprint(w, `{"err":0}`)
+		}
+	}
+}
+
+func TestActiveJobGuard(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(r *RRFFileManager) error
+	}{
+		{"delete file", func(r *RRFFileManager) error { return r.Delete(ctx, "0:/gcodes/sub/a.g") }},
+		{"delete file without volume", func(r *RRFFileManager) error { return r.Delete(ctx, "/gcodes/sub/a.g") }},
+		{"delete parent", func(r *RRFFileManager) error { return r.Delete(ctx, "0:/gcodes/sub/") }},
+		{"move file", func(r *RRFFileManager) error { return r.Move(ctx, "0:/gcodes/sub/a.g", "0:/gcodes/b.g") }},
+		{"move parent", func(r *RRFFileManager) error { return r.Move(ctx, "0:/gcodes/sub", "0:/gcodes/other") }},
+		{"delete recursive file", func(r *RRFFileManager) error { return r.DeleteRecursive(ctx, "0:/gcodes/sub/a.g") }},
+		{"delete recursive parent", func(r *RRFFileManager) error { return r.DeleteRecursive(ctx, "0:/gcodes") }},
+		{"delete recursive root", func(r *RRFFileManager) error { return r.DeleteRecursive(ctx, "0:/") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changed []string
+			r := newTestManager(t, printingHandler(&changed), WithActiveJobGuard(true))
+			if err := tt.call(r); err != ErrFileInUse {
+				t.Errorf("err = %v, want ErrFileInUse", err)
+			}
+			if len(changed) > 0 {
+				t.Errorf("sent changes for %q", changed)
+			}
+		})
+	}
+}
+
+func TestActiveJobGuardAllowsOthers(t *testing.T) {
+	ctx := context.Background()
+	var changed []string
+	r := newTestManager(t, printingHandler(&changed), WithActiveJobGuard(true))
+
+	// A sibling sharing the name as prefix is not a parent of the job
+	if err := r.Delete(ctx, "0:/gcodes/su"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := r.Move(ctx, "0:/gcodes/sub/b.g", "0:/gcodes/b.g"); err != nil {
+		t.Errorf("Move: %v", err)
+	}
+	if want := []string{"0:/gcodes/su", "0:/gcodes/sub/b.g"}; fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Errorf("changed %q, want %q", changed, want)
+	}
+}
+
+func TestActiveJobGuardDisabledByDefault(t *testing.T) {
+	ctx := context.Background()
+	var changed []string
+	r := newTestManager(t, printingHandler(&changed))
+
+	if err := r.Delete(ctx, "0:/gcodes/sub/a.g"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := r.Move(ctx, "0:/gcodes/sub", "0:/gcodes/other"); err != nil {
+		t.Errorf("Move: %v", err)
+	}
+	if want := []string{"0:/gcodes/sub/a.g", "0:/gcodes/sub"}; fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Errorf("changed %q, want %q", changed, want)
+	}
+}
@@ -0,0 +1,235 @@
+package librfm
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// RecentFiles recursively lists dir and returns the n most recently modified files
+// with Name set to their full path. Files with the same modification date are
+// ordered by path. If n is not positive all files are returned.
+func (r *RRFFileManager) RecentFiles(ctx context.Context, dir string, n int) ([]File, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	fl, err := r.Filelist(ctx, dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0)
+	fl.Walk(func(dir string, file File) error {
+		if file.IsFile() {
+			file.Name = JoinPath(dir, file.Name)
+			files = append(files, file)
+		}
+		return nil
+	})
+
+	// Sort newest first and by name
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Date().Equal(files[j].Date()) {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].Date().After(files[j].Date())
+	})
+	if n > 0 && n < len(files) {
+		files = files[:n]
+	}
+	return files, nil
+}
+
+// DirLastModified returns the last modification time of the directory dir. Since
+// rr_fileinfo only handles files this is read from the listing of its parent
+// directory. It returns ErrDirectoryNotFound if there is no such directory and the
+// zero time for the root of a volume which does not carry a timestamp.
+func (r *RRFFileManager) DirLastModified(ctx context.Context, dir string) (time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	parent, name := SplitPath(dir)
+	if name == "" {
+		return time.Time{}, nil
+	}
+	fl, err := r.Filelist(ctx, parent, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, f := range fl.Files {
+		if f.IsDir() && f.Name == name {
+			return f.Date(), nil
+		}
+	}
+	return time.Time{}, ErrDirectoryNotFound
+}
+
+// FilelistSince works like Filelist but only keeps files modified after since.
+// RRF cannot filter by date so this is done after listing. In recursive mode
+// directories are only kept where they lead to matching files and Subdirs
+// without any are pruned from the tree. Otherwise directories are kept if they
+// have been modified after since themselves.
+func (r *RRFFileManager) FilelistSince(ctx context.Context, dir string, since time.Time, recursive bool) (*Filelist, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	fl, err := r.Filelist(ctx, dir, recursive)
+	if err != nil {
+		return nil, err
+	}
+	filterSince(fl, since, recursive)
+	return fl, nil
+}
+
+// filterSince removes everything from fl not modified after since and returns
+// whether anything is left
+func filterSince(fl *Filelist, since time.Time, recursive bool) bool {
+	kept := make(map[string]bool)
+	subdirs := fl.Subdirs[:0]
+	for _, subdir := range fl.Subdirs {
+		if filterSince(subdir, since, recursive) {
+			_, name := SplitPath(subdir.Dir)
+			kept[name] = true
+			subdirs = append(subdirs, subdir)
+		}
+	}
+	fl.Subdirs = subdirs
+
+	files := fl.Files[:0]
+	for _, f := range fl.Files {
+		keep := f.Date().After(since)
+		if f.IsDir() && recursive {
+			keep = kept[f.Name]
+		}
+		if keep {
+			files = append(files, f)
+		}
+	}
+	fl.Files = files
+	return len(files) > 0
+}
+
+// ListDirs returns only the directory entries of dir. RRF offers no way to
+// request directories only so they are filtered from the full listing.
+func (r *RRFFileManager) ListDirs(ctx context.Context, dir string) ([]File, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	fl, err := r.Filelist(ctx, dir, false)
+	if err != nil {
+		return nil, err
+	}
+	dirs := make([]File, 0)
+	for _, f := range fl.Files {
+		if f.IsDir() {
+			dirs = append(dirs, f)
+		}
+	}
+	return dirs, nil
+}
+
+// IsEmpty returns whether the directory dir has no entries, e.g. to decide between
+// Delete and DeleteRecursive. It returns ErrDirectoryNotFound if there is no such
+// directory.
+func (r *RRFFileManager) IsEmpty(ctx context.Context, dir string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	fl, err := r.Filelist(ctx, dir, false)
+	if err != nil {
+		return false, err
+	}
+	return len(fl.Files) == 0, nil
+}
+
+// Exists checks whether a file or directory with the given path exists. This is
+// done by listing its parent directory so it works for directories as well.
+func (r *RRFFileManager) Exists(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return false, err
+	}
+	parent, name := SplitPath(path)
+	if name == "" {
+		_, err := r.Filelist(ctx, parent, false)
+		if err == ErrDirectoryNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	fl, err := r.Filelist(ctx, parent, false)
+	if err == ErrDirectoryNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, f := range fl.Files {
+		if f.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DirStats counts the files and directories below dir and sums up the size of
+// all files. Unlike building the tree with a recursive Filelist only a single
+// directory listing is held in memory at any time.
+func (r *RRFFileManager) DirStats(ctx context.Context, dir string, recursive bool) (files int, dirs int, totalSize uint64, err error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	if err := checkPath(dir); err != nil {
+		return 0, 0, 0, err
+	}
+	pending := []string{cleanPath(dir)}
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return files, dirs, totalSize, &TraversalError{Path: pending[len(pending)-1], Err: err}
+		}
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		fl, err := r.getFullFilelist(ctx, current, 0)
+		if err != nil {
+			return files, dirs, totalSize, traversalError(ctx, current, err)
+		}
+		for _, f := range fl.Files {
+			if f.IsDir() {
+				dirs++
+				if recursive {
+					pending = append(pending, JoinPath(fl.Dir, f.Name))
+				}
+				continue
+			}
+			files++
+			totalSize += f.Size
+		}
+	}
+	return files, dirs, totalSize, nil
+}
+
+// WaitForFile polls every pollInterval until a file or directory with the given
+// path exists or ctx is done, e.g. to wait for a file generated by a G-code.
+func (r *RRFFileManager) WaitForFile(ctx context.Context, path string, pollInterval time.Duration) error {
+	path = r.resolvePath(path)
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		exists, err := r.Exists(ctx, path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
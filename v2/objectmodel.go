@@ -0,0 +1,36 @@
+package librfm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrNoObjectModel is the error returned if the board did not provide the requested
+// object model key, e.g. because it runs a firmware version without object model
+var ErrNoObjectModel = errors.New("Object model not available")
+
+type modelResponse struct {
+	Key    string
+	Flags  string
+	Result json.RawMessage
+}
+
+// getModel queries the object model for the given key and decodes its result into v
+func (r *RRFFileManager) getModel(ctx context.Context, key, flags string, v interface{}) error {
+	vals := r.query("key", key, "flags", flags)
+	body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(modelURL, r.baseURL, vals))
+	if err != nil {
+		return err
+	}
+
+	var m modelResponse
+	if err := json.Unmarshal(body, &m); err != nil {
+		return ErrNoObjectModel
+	}
+	if len(m.Result) == 0 || string(m.Result) == "null" {
+		return ErrNoObjectModel
+	}
+	return json.Unmarshal(m.Result, v)
+}
@@ -0,0 +1,274 @@
+package librfm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Option configures optional behavior of an RRFFileManager
+type Option func(*RRFFileManager)
+
+// WithTimeout sets a default timeout for every operation of the manager. It covers
+// all requests an operation sends, e.g. for every directory of a recursive Filelist
+// or for retries of a busy board, and is only applied if the context passed to the
+// operation does not already have a deadline. WaitForFile and ConnectResilient
+// apply it to each of their attempts instead of the whole operation and iterators
+// to each page they fetch.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *RRFFileManager) {
+		r.timeout = timeout
+	}
+}
+
+// withTimeout derives a context using the configured default timeout unless
+// none is set or ctx already has a deadline of its own
+func (r *RRFFileManager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// WithCancelablePartialCleanup makes Upload delete the target file on the board
+// if the upload was aborted by cancelling its context. Without this option a
+// cancelled upload may leave a partial file behind.
+func WithCancelablePartialCleanup(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.partialCleanup = enabled
+	}
+}
+
+// WithTransport replaces the default transport of the underlying HTTP client,
+// e.g. to use a tuned DialContext or custom connection pooling. tr is never
+// modified: if other options change settings of the transport the manager uses
+// a copy of tr instead, which does not share its idle connections.
+func WithTransport(tr *http.Transport) Option {
+	return func(r *RRFFileManager) {
+		r.httpClient.Transport = tr
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of requests that are sent to the
+// board at the same time across all operations of this manager. RRF's web server
+// handles only very few connections in parallel so the default is a conservative
+// 2. Duet 3 boards with more capable networking may cope with a higher value. A
+// value of 0 or less removes the limit.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(r *RRFFileManager) {
+		r.maxRequests = n
+	}
+}
+
+// WithJitter makes every request wait for a random duration up to jitter before
+// it is sent to spread bursts of requests from batch operations
+func WithJitter(jitter time.Duration) Option {
+	return func(r *RRFFileManager) {
+		r.jitter = jitter
+	}
+}
+
+// acquireSlot waits for the optional jitter and a free request slot and returns
+// a function to release it again
+func (r *RRFFileManager) acquireSlot(ctx context.Context) (func(), error) {
+	if r.jitter > 0 {
+		t := time.NewTimer(time.Duration(rand.Int63n(int64(r.jitter))))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	if r.requestSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case r.requestSlots <- struct{}{}:
+		return func() { <-r.requestSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WithFileinfoCache enables caching results of Fileinfo for the given ttl. Entries
+// are invalidated when the path is changed through Upload, Move or Delete of this
+// manager but changes by other clients will go unnoticed until the ttl expired.
+// The cache is disabled by default.
+func WithFileinfoCache(ttl time.Duration) Option {
+	return func(r *RRFFileManager) {
+		if ttl > 0 {
+			r.fileinfoCache = newFileinfoCache(ttl)
+		} else {
+			r.fileinfoCache = nil
+		}
+	}
+}
+
+// WithUploadRetries makes Upload retry up to retries times if the board rejects
+// the uploaded file, e.g. because of a CRC mismatch after a corrupted transmission.
+// RRF does not report CRC mismatches with a distinct error code so any failure
+// reported by rr_upload is retried. After all retries failed the returned error
+// wraps ErrUploadRetriesExhausted.
+func WithUploadRetries(retries int) Option {
+	return func(r *RRFFileManager) {
+		r.uploadRetries = retries
+	}
+}
+
+// WithMaxResponseSize limits the size of responses to requests other than
+// downloads to protect against a malfunctioning board or proxy sending an
+// enormous body. Larger responses fail with ErrResponseTooLarge. The default
+// is 32 MiB, a value of 0 or less removes the limit.
+func WithMaxResponseSize(size int64) Option {
+	return func(r *RRFFileManager) {
+		r.maxResponseSize = size
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections kept by the
+// transport. The transport's default is used if this is not set.
+func WithMaxIdleConns(n int) Option {
+	return func(r *RRFFileManager) {
+		r.maxIdleConns = n
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the maximum number of idle connections kept to
+// the board. The transport's default is used if this is not set.
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(r *RRFFileManager) {
+		r.maxIdleConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection is kept before it is
+// closed. The transport's default is used if this is not set.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(r *RRFFileManager) {
+		r.idleConnTimeout = timeout
+	}
+}
+
+// WithKeepalive makes the manager send a cheap request at half the session timeout
+// reported by the board after each successful Connect so the session is not dropped
+// while the manager is idle
+func WithKeepalive(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.keepalive = enabled
+	}
+}
+
+// WithConnectInterval sets the minimum time between two connect attempts. Some
+// firmware rejects connects issued in quick succession so Connect waits until the
+// interval has passed. The default is 2s, a value of 0 disables waiting.
+func WithConnectInterval(interval time.Duration) Option {
+	return func(r *RRFFileManager) {
+		r.connectInterval = interval
+	}
+}
+
+// WithHTTPS makes the manager talk to the board via HTTPS, e.g. when it is
+// placed behind a TLS terminating reverse proxy
+func WithHTTPS(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.https = enabled
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS connections and
+// enables HTTPS. cfg is copied so WithRootCAs and WithInsecureSkipVerify never
+// modify the caller's configuration. Like all TLS options it is applied to a copy
+// of a transport passed with WithTransport.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(r *RRFFileManager) {
+		r.https = true
+		r.tlsConfig = cfg.Clone()
+	}
+}
+
+// WithRootCAs sets the certificate authorities trusted when verifying the
+// certificate of the board or its proxy, e.g. an internal CA, and enables HTTPS
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(r *RRFFileManager) {
+		r.https = true
+		r.ensureTLSConfig().RootCAs = pool
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the certificate presented by the
+// board or its proxy and enables HTTPS. This is UNSAFE since it allows anyone to
+// intercept the connection and should only be used for testing in a lab.
+func WithInsecureSkipVerify() Option {
+	return func(r *RRFFileManager) {
+		r.https = true
+		r.ensureTLSConfig().InsecureSkipVerify = true
+	}
+}
+
+func (r *RRFFileManager) ensureTLSConfig() *tls.Config {
+	if r.tlsConfig == nil {
+		r.tlsConfig = &tls.Config{}
+	}
+	return r.tlsConfig
+}
+
+// WithStrictDecoding makes parsing of connect, filelist and fileinfo responses fail
+// on unknown fields or trailing data. This is useful during development to notice
+// changes of the firmware's responses. By default decoding is lenient.
+func WithStrictDecoding(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.strictDecoding = enabled
+	}
+}
+
+// WithBusyRetries makes requests rejected with 503 Service Unavailable or 429 Too Many
+// Requests be retried up to retries times. Before each retry the delay given by the
+// response's Retry-After header is waited for, capped at maxWait. Without the header
+// a short default delay is used. A maxWait of zero keeps the default cap.
+func WithBusyRetries(retries int, maxWait time.Duration) Option {
+	return func(r *RRFFileManager) {
+		r.busyRetries = retries
+		if maxWait > 0 {
+			r.maxRetryAfter = maxWait
+		}
+	}
+}
+
+// WithLocation sets the timezone of the board's clock. RRF sends and expects
+// timestamps as wall clock without offset, so uploads and listings use loc to
+// translate them. By default local time is assumed which is ambiguous during the
+// hour repeated when daylight saving time ends. Running the board in UTC and
+// passing time.UTC here gives unambiguous modification times all year.
+func WithLocation(loc *time.Location) Option {
+	return func(r *RRFFileManager) {
+		if loc != nil {
+			r.location = loc
+		}
+	}
+}
+
+// WithLiteralSlashes controls how slashes in paths sent as query parameters are
+// encoded. By default they are escaped as %2F which RRF itself handles fine. Some
+// reverse proxies however decode %2F or reject it so paths arrive broken at the
+// board. Enabling this sends slashes literally which is also valid in a query.
+func WithLiteralSlashes(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.literalSlashes = enabled
+	}
+}
+
+// WithDirectoriesInIndex makes Filelist.Contains and Lookup also find directory
+// entries of listings whose subdirectories were not fetched. By default only
+// directories with their own listing in the Filelist are found, matching v1.
+func WithDirectoriesInIndex(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.indexDirs = enabled
+	}
+}
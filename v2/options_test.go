@@ -0,0 +1,119 @@
+package librfm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSOptionsKeepCallerConfig(t *testing.T) {
+	cfg := &tls.Config{ServerName: "duet.local"}
+	pool := x509.NewCertPool()
+	// A custom dialer keeps net/http from setting up HTTP/2 defaults in the
+	// caller's TLSClientConfig when the transport is copied
+	tr := &http.Transport{DialContext: (&net.Dialer{}).DialContext}
+	r := New("duet.local", 443, false, WithTransport(tr), WithTLSConfig(cfg), WithRootCAs(pool), WithInsecureSkipVerify())
+
+	if cfg.RootCAs != nil || cfg.InsecureSkipVerify {
+		t.Errorf("caller's config was modified: %+v", cfg)
+	}
+	if tr.TLSClientConfig != nil {
+		t.Errorf("caller's transport was modified: %+v", tr.TLSClientConfig)
+	}
+	if used := r.httpClient.Transport.(*http.Transport); used == tr || used.TLSClientConfig != r.tlsConfig {
+		t.Error("manager does not use its own TLS config on a copy of the transport")
+	}
+	if r.tlsConfig == cfg {
+		t.Fatal("manager uses the caller's config")
+	}
+	if r.tlsConfig.ServerName != "duet.local" || r.tlsConfig.RootCAs != pool || !r.tlsConfig.InsecureSkipVerify {
+		t.Errorf("manager config = %+v", r.tlsConfig)
+	}
+}
+
+// slowTree serves a listing where every directory up to depth has a subdirectory
+// and answers every request after delay
+func slowTree(delay time.Duration, depth int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		dir := req.URL.Query().Get("dir")
+		files := `[]`
+		if strings.Count(dir, "/") < depth {
+			files = `[{"type":"d","name":"sub","size":0,"date":"2024-01-01T00:00:00"}]`
+		}
+		fmt.Fprintf(w, `{"dir":%q,"first":0,"files":%s,"next":0}`, dir, files)
+	}
+}
+
+func TestTimeoutCoversOperation(t *testing.T) {
+	r := newTestManager(t, slowTree(50*time.Millisecond, 6), WithTimeout(150*time.Millisecond))
+
+	// Every single request is faster than the timeout but not the whole listing
+	_, err := r.Filelist(context.Background(), "0:/gcodes", true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("recursive Filelist = %v, want deadline exceeded", err)
+	}
+}
+
+func TestTimeoutCoversBusyRetries(t *testing.T) {
+	var requests int
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, WithTimeout(200*time.Millisecond), WithBusyRetries(5, time.Second))
+
+	start := time.Now()
+	_, err := r.Fileinfo(context.Background(), "0:/gcodes/a.g")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Fileinfo = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Fileinfo took %s", elapsed)
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want 1", requests)
+	}
+}
+
+func TestCallerDeadlineWins(t *testing.T) {
+	r := newTestManager(t, slowTree(50*time.Millisecond, 3), WithTimeout(20*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fl, err := r.Filelist(ctx, "0:/gcodes", true)
+	if err != nil {
+		t.Fatalf("Filelist with caller deadline: %v", err)
+	}
+	if len(fl.Subdirs) != 1 {
+		t.Errorf("Subdirs = %d, want 1", len(fl.Subdirs))
+	}
+}
+
+func TestTransportOptionsKeepCallerTransport(t *testing.T) {
+	tr := &http.Transport{MaxIdleConns: 7}
+	r := New("duet.local", 80, false, WithTransport(tr), WithMaxIdleConns(3), WithMaxIdleConnsPerHost(2), WithIdleConnTimeout(time.Minute))
+
+	if tr.MaxIdleConns != 7 || tr.MaxIdleConnsPerHost != 0 || tr.IdleConnTimeout != 0 {
+		t.Errorf("caller's transport was modified: %+v", tr)
+	}
+	used, ok := r.httpClient.Transport.(*http.Transport)
+	if !ok || used == tr {
+		t.Fatal("manager uses the caller's transport")
+	}
+	if used.MaxIdleConns != 3 || used.MaxIdleConnsPerHost != 2 || used.IdleConnTimeout != time.Minute {
+		t.Errorf("manager transport = %+v", used)
+	}
+
+	// Without settings to change the transport is shared as is
+	if r := New("duet.local", 80, false, WithTransport(tr)); r.httpClient.Transport != tr {
+		t.Error("untuned transport was copied")
+	}
+}
@@ -0,0 +1,89 @@
+package librfm
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// ErrInvalidPath is the error returned if an operation is called with an empty path
+var ErrInvalidPath = errors.New("Invalid path")
+
+// checkPath returns ErrInvalidPath if p is empty or only consists of whitespace
+func checkPath(p string) error {
+	if strings.TrimSpace(p) == "" {
+		return ErrInvalidPath
+	}
+	return nil
+}
+
+// splitVolume separates a leading volume specifier like "0:" from the rest of p
+func splitVolume(p string) (volume, rest string) {
+	i := strings.IndexByte(p, ':')
+	if i <= 0 {
+		return "", p
+	}
+	for _, c := range p[:i] {
+		if c < '0' || c > '9' {
+			return "", p
+		}
+	}
+	return p[:i+1], p[i+1:]
+}
+
+// cleanPath normalizes a path before it is sent to RRF. It converts backslashes
+// to forward slashes, collapses duplicate slashes and resolves "." and ".."
+// elements while keeping a leading volume specifier like "0:" intact.
+func cleanPath(p string) string {
+	if p == "" {
+		return p
+	}
+	volume, rest := splitVolume(strings.ReplaceAll(p, `\`, "/"))
+	if rest == "" {
+		return volume + "/"
+	}
+	rest = path.Clean(rest)
+	if volume != "" && !strings.HasPrefix(rest, "/") {
+
+		// Anything following a volume is relative to its root
+		rest = path.Clean("/" + rest)
+	}
+	return volume + rest
+}
+
+// SplitPath normalizes p and splits it into its parent directory and the final
+// path element respecting RRF's volume prefix. The parent of an element at the
+// root of a volume is the volume root itself, e.g. "0:/file" is split into "0:/"
+// and "file". The root of a volume has an empty name.
+func SplitPath(p string) (dir, name string) {
+	volume, rest := splitVolume(cleanPath(p))
+	i := strings.LastIndexByte(rest, '/')
+	if i < 0 {
+		return volume, rest
+	}
+	dir, name = rest[:i], rest[i+1:]
+	if dir == "" {
+		dir = "/"
+	}
+	return volume + dir, name
+}
+
+// ParentDir returns the parent directory of p, e.g. "0:/" for "0:/file"
+func ParentDir(p string) string {
+	dir, _ := SplitPath(p)
+	return dir
+}
+
+// JoinPath joins the given path elements with single slashes and normalizes the
+// result like all paths sent to RRF, e.g. JoinPath("0:/", "gcodes/", "a.g") returns
+// "0:/gcodes/a.g". A leading volume specifier is kept and ".." elements cannot leave
+// the root of the volume. Empty elements are ignored.
+func JoinPath(elem ...string) string {
+	parts := make([]string, 0, len(elem))
+	for _, e := range elem {
+		if e != "" {
+			parts = append(parts, e)
+		}
+	}
+	return cleanPath(strings.Join(parts, "/"))
+}
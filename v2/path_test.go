@@ -0,0 +1,83 @@
+package librfm
+
+import "testing"
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"0:", "0:/"},
+		{"0:/", "0:/"},
+		{"1:", "1:/"},
+		{"0://gcodes//job.gcode", "0:/gcodes/job.gcode"},
+		{"0:/gcodes/../macros/x.g", "0:/macros/x.g"},
+		{"0:/gcodes/./job.gcode", "0:/gcodes/job.gcode"},
+		{"0:/gcodes/", "0:/gcodes"},
+		{`0:\gcodes\job.gcode`, "0:/gcodes/job.gcode"},
+		{`0:/gcodes\sub/job.gcode`, "0:/gcodes/sub/job.gcode"},
+		{"0:gcodes/job.gcode", "0:/gcodes/job.gcode"},
+		{"0:/..", "0:/"},
+		{"0:/../../sys/config.g", "0:/sys/config.g"},
+		{"/gcodes//job.gcode", "/gcodes/job.gcode"},
+		{"/../job.gcode", "/job.gcode"},
+		{"gcodes/../job.gcode", "job.gcode"},
+	}
+	for _, tt := range tests {
+		if got := cleanPath(tt.in); got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		in   string
+		dir  string
+		name string
+	}{
+		{"0:/", "0:/", ""},
+		{"0:", "0:/", ""},
+		{"0:/file", "0:/", "file"},
+		{"0:/gcodes/sub/a.g", "0:/gcodes/sub", "a.g"},
+		{"0:/gcodes/sub/", "0:/gcodes", "sub"},
+		{"0:/gcodes//sub//", "0:/gcodes", "sub"},
+		{"/gcodes/a.g", "/gcodes", "a.g"},
+		{"/a.g", "/", "a.g"},
+		{"a.g", "", "a.g"},
+	}
+	for _, tt := range tests {
+		dir, name := SplitPath(tt.in)
+		if dir != tt.dir || name != tt.name {
+			t.Errorf("SplitPath(%q) = %q, %q; want %q, %q", tt.in, dir, name, tt.dir, tt.name)
+		}
+		if parent := ParentDir(tt.in); parent != tt.dir {
+			t.Errorf("ParentDir(%q) = %q, want %q", tt.in, parent, tt.dir)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		elem []string
+		want string
+	}{
+		{[]string{"0:/", "gcodes/", "a.g"}, "0:/gcodes/a.g"},
+		{[]string{"0:", "a.g"}, "0:/a.g"},
+		{[]string{"0:/", ""}, "0:/"},
+		{[]string{"0:/gcodes", "sub/dir", "a.g"}, "0:/gcodes/sub/dir/a.g"},
+		{[]string{"0:/gcodes//", "//sub//", "/a.g"}, "0:/gcodes/sub/a.g"},
+		{[]string{"0:/gcodes", "", "a.g"}, "0:/gcodes/a.g"},
+		{[]string{"0:/gcodes", `sub\a.g`}, "0:/gcodes/sub/a.g"},
+		{[]string{"0:/gcodes", "../sys/config.g"}, "0:/sys/config.g"},
+		{[]string{"0:/gcodes", "../../../a.g"}, "0:/a.g"},
+		{[]string{"0:/", ".."}, "0:/"},
+		{[]string{"/gcodes", "a.g"}, "/gcodes/a.g"},
+	}
+	for _, tt := range tests {
+		if got := JoinPath(tt.elem...); got != tt.want {
+			t.Errorf("JoinPath(%q) = %q, want %q", tt.elem, got, tt.want)
+		}
+	}
+}
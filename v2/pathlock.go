@@ -0,0 +1,55 @@
+package librfm
+
+import (
+	"context"
+	"sync"
+)
+
+// pathLocks serializes operations on the same path within a manager
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock is held by one operation at a time and shared by all waiting for it
+type pathLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// lock waits until no other operation holds path or ctx is done and returns a
+// function to release the lock again
+func (p *pathLocks) lock(ctx context.Context, path string) (func(), error) {
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = make(map[string]*pathLock)
+	}
+	l, ok := p.locks[path]
+	if !ok {
+		l = &pathLock{ch: make(chan struct{}, 1)}
+		p.locks[path] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	select {
+	case l.ch <- struct{}{}:
+		return func() {
+			<-l.ch
+			p.unref(path, l)
+		}, nil
+	case <-ctx.Done():
+		p.unref(path, l)
+		return nil, ctx.Err()
+	}
+}
+
+// unref drops a reference to l and forgets it once nobody holds or waits for it
+func (p *pathLocks) unref(path string, l *pathLock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(p.locks, path)
+	}
+}
@@ -0,0 +1,28 @@
+package librfm
+
+import (
+	"net/url"
+	"strings"
+)
+
+// query encodes the given key value pairs as URL query keeping them in the given
+// order. Unlike url.Values.Encode, which sorts by key, this sends parameters in the
+// order RRF documents them and the way v1 of this library does, so requests are
+// identical between both versions and firmware parsing positionally is served.
+// Slashes in values are sent literally if enabled with WithLiteralSlashes.
+func (r *RRFFileManager) query(kv ...string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(kv[i]))
+		sb.WriteByte('=')
+		v := url.QueryEscape(kv[i+1])
+		if r.literalSlashes {
+			v = strings.ReplaceAll(v, "%2F", "/")
+		}
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
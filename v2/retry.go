@@ -0,0 +1,64 @@
+package librfm
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRetryAfter is waited before retrying a busy request without Retry-After
+	defaultRetryAfter = time.Second
+
+	// defaultMaxRetryAfter caps the delay requested by a Retry-After header
+	defaultMaxRetryAfter = 30 * time.Second
+)
+
+// busy returns true if the board rejected the request because it is busy
+func (e *StatusError) busy() bool {
+	return e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusTooManyRequests
+}
+
+// retryWait returns how long to wait before retrying the request that failed with e
+func (r *RRFFileManager) retryWait(e *StatusError) time.Duration {
+	wait := e.RetryAfter
+	if wait <= 0 {
+		wait = defaultRetryAfter
+	}
+	if wait > r.maxRetryAfter {
+		wait = r.maxRetryAfter
+	}
+	return wait
+}
+
+// parseRetryAfter parses the value of a Retry-After header which is either a
+// number of seconds or an HTTP date. It returns zero if v is empty or invalid.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// sleepCtx waits for d or until ctx is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -3,19 +3,22 @@ package librfm
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -28,105 +31,343 @@ const (
 	moveURL              = "%s/rr_move?%s"
 	downloadURL          = "%s/rr_download?%s"
 	deleteURL            = "%s/rr_delete?%s"
+	modelURL             = "%s/rr_model?%s"
+	configURL            = "%s/rr_config"
+	warmupURL            = "%s/rr_model?key=state.upTime"
+	endpointURL          = "%s/%s?%s"
+	gcodeURL             = "%s/rr_gcode?%s"
+	replyURL             = "%s/rr_reply"
+	disconnectURL        = "%s/rr_disconnect"
+	statusURL            = "%s/rr_status?%s"
 	typeDirectory        = "d"
 	typeFile             = "f"
 	errDriveNotMounted   = 1
 	errDirectoryNotExist = 2
+	// errUploadFailed is reported by rr_upload for any failure including a CRC mismatch
+	errUploadFailed = 1
+	cleanupTimeout  = 10 * time.Second
+	// defaultMaxRequests is the default number of concurrent requests to the board
+	defaultMaxRequests = 2
+	// defaultMaxResponseSize is the default limit for responses other than downloads
+	defaultMaxResponseSize = 32 << 20
 	// TimeFormat is the format of timestamps used by RRF
 	TimeFormat = "2006-01-02T15:04:05"
 )
 
+// ErrorCode is the numeric error code reported by RRF where 0 means no error.
+// Some firmware or proxy combinations send it as a string so both encodings
+// are accepted when unmarshalling.
+type ErrorCode uint64
+
+// UnmarshalJSON decodes an error code given either as JSON number or string
+func (e *ErrorCode) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*e = 0
+		return nil
+	}
+	c, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*e = ErrorCode(c)
+	return nil
+}
+
 type errorResponse struct {
-	Err uint64
+	Err ErrorCode
+	// Reply, Response and Message may carry a human readable description of the error
+	Reply    string
+	Response string
+	Message  string
+}
+
+// message returns the first non-empty error description of the response
+func (e *errorResponse) message() string {
+	for _, m := range []string{e.Reply, e.Response, e.Message} {
+		if m = strings.TrimSpace(m); m != "" {
+			return m
+		}
+	}
+	return ""
 }
 
 // RRFFileManager provides means to interact with SD card contents on a machine
 // using RepRapFirmware (RRF). It will communicate through its HTTP interface.
 type RRFFileManager struct {
-	httpClient *http.Client
-	baseURL    string
-	debug      bool
+	httpClient          *http.Client
+	baseURL             string
+	debug               bool
+	timeout             time.Duration
+	partialCleanup      bool
+	maxRequests         int
+	requestSlots        chan struct{}
+	jitter              time.Duration
+	fwMu                sync.Mutex
+	fwVersion           string
+	fwGen               uint64
+	sessMu              sync.Mutex
+	sessionKey          uint64
+	connected           bool
+	lost                chan struct{}
+	lostClosed          bool
+	sessionTimeout      time.Duration
+	keepalive           bool
+	keepaliveStop       chan struct{}
+	connectInterval     time.Duration
+	lastConnect         time.Time
+	https               bool
+	tlsConfig           *tls.Config
+	strictDecoding      bool
+	fileinfoCache       *fileinfoCache
+	uploadRetries       int
+	maxResponseSize     int64
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	busyRetries         int
+	maxRetryAfter       time.Duration
+	requestHook         func(RequestEvent)
+	tracing             bool
+	location            *time.Location
+	wdMu                sync.Mutex
+	workingDir          string
+	stats               transferStats
+	literalSlashes      bool
+	indexDirs           bool
+	jobGuard            bool
+	contentTypeFunc     func(path string) string
+	uploadLocks         pathLocks
 }
 
 // New creates a new instance of RRFFileManager
-func New(domain string, port uint64, debug bool) *RRFFileManager {
+func New(domain string, port uint64, debug bool, opts ...Option) *RRFFileManager {
 	tr := &http.Transport{DisableCompression: true}
-	return &RRFFileManager{
-		httpClient: &http.Client{Transport: tr},
-		baseURL:    fmt.Sprintf("http://%s:%d", domain, port),
-		debug:      debug,
+	r := &RRFFileManager{
+		httpClient:      &http.Client{Transport: tr},
+		baseURL:         fmt.Sprintf("http://%s:%d", domain, port),
+		debug:           debug,
+		maxRequests:     defaultMaxRequests,
+		maxResponseSize: defaultMaxResponseSize,
+		connectInterval: defaultConnectInterval,
+		maxRetryAfter:   defaultMaxRetryAfter,
+		location:        time.Local,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.https {
+		r.baseURL = fmt.Sprintf("https://%s:%d", domain, port)
 	}
+	if r.maxRequests > 0 {
+		r.requestSlots = make(chan struct{}, r.maxRequests)
+	}
+	tuned := r.maxIdleConns > 0 || r.maxIdleConnsPerHost > 0 || r.idleConnTimeout > 0 || r.tlsConfig != nil
+	if tr, ok := r.httpClient.Transport.(*http.Transport); ok && tuned {
+
+		// The transport might have been passed with WithTransport and be shared
+		// with other managers so only a copy of it is changed
+		tr = tr.Clone()
+		r.httpClient.Transport = tr
+		if r.maxIdleConns > 0 {
+			tr.MaxIdleConns = r.maxIdleConns
+		}
+		if r.maxIdleConnsPerHost > 0 {
+			tr.MaxIdleConnsPerHost = r.maxIdleConnsPerHost
+		}
+		if r.idleConnTimeout > 0 {
+			tr.IdleConnTimeout = r.idleConnTimeout
+		}
+		if r.tlsConfig != nil {
+			tr.TLSClientConfig = r.tlsConfig
+		}
+	}
+	return r
 }
 
 // doGetRequest will perform a GET request on the given URL and return
 // the content of the response, a duration on how long it took (including
 // setup of connection) or an error in case something went wrong
 func (r *RRFFileManager) doGetRequest(ctx context.Context, url string) ([]byte, *time.Duration, error) {
-	if r.debug {
-		log.Printf("Doing GET request to %s", url)
-	}
-	start := time.Now()
+	return r.doRequest(ctx, http.MethodGet, url, nil, nil, r.maxResponseSize)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
+// jsonHeader asks intermediaries to serve the JSON responses RRF sends for its
+// rr_* metadata endpoints
+var jsonHeader = http.Header{"Accept": {jsonContentType}}
+
+// doJSONRequest performs a GET request like doGetRequest on an endpoint answering
+// with JSON. The response is rejected with a *ContentTypeError if it declares a
+// content type that is not JSON, e.g. a login page of a proxy.
+func (r *RRFFileManager) doJSONRequest(ctx context.Context, url string) ([]byte, *time.Duration, error) {
+	return r.doRequest(ctx, http.MethodGet, url, nil, jsonHeader, r.maxResponseSize)
+}
+
+// doPostRequest will perform a POST request on the given URL and return
+// the content of the response, a duration on long it tool (including
+// setup of connection) or an error in case something went wrong
+func (r *RRFFileManager) doPostRequest(ctx context.Context, url string, content *sharedBuffer, header http.Header) ([]byte, *time.Duration, error) {
+	return r.doRequest(ctx, http.MethodPost, url, content, header, r.maxResponseSize)
+}
+
+// doRequest performs a request with the given method, body and additional
+// headers and returns the content of the response and how long it took.
+// If limit is positive responses larger than limit bytes are rejected.
+func (r *RRFFileManager) doRequest(ctx context.Context, method, url string, content *sharedBuffer, header http.Header, limit int64) ([]byte, *time.Duration, error) {
+	resp, err := r.roundTrip(ctx, method, url, content, header, limit, nil)
+	if resp == nil {
 		return nil, nil, err
 	}
-	if r.debug {
-		dump, _ := httputil.DumpRequestOut(req, false)
-		log.Println(string(dump))
-	}
-
-	resp, err := r.httpClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, &resp.duration, err
 	}
-	defer resp.Body.Close()
+	return resp.body, &resp.duration, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	duration := time.Since(start)
-	if r.debug {
-		log.Printf("Received response\n%s\n%s", printHeaders(resp), printableBody(body))
+// response holds what roundTrip received from the board
+type response struct {
+	body       []byte
+	header     http.Header
+	statusCode int
+	duration   time.Duration
+}
+
+// doStreamRequest performs a GET request on an endpoint answering with JSON and passes the body of a successful response
+// to sink while it is received instead of reading it into memory first
+func (r *RRFFileManager) doStreamRequest(ctx context.Context, url string, sink func(io.Reader) error) (*time.Duration, error) {
+	resp, err := r.roundTrip(ctx, http.MethodGet, url, nil, jsonHeader, r.maxResponseSize, func(_ int, body io.Reader) error {
+		return sink(body)
+	})
+	if resp == nil {
+		return nil, err
 	}
-	if err != nil {
-		return nil, nil, err
+	return &resp.duration, err
+}
+
+// roundTrip performs a request like doRequest but also returns the response headers.
+// The returned response is nil if no response was received at all. Requests the
+// board rejected as busy are retried as configured with WithBusyRetries. Unless ctx
+// already has a deadline the timeout set with WithTimeout covers all attempts. If
+// sink is not nil the status code and body of a successful response are passed to
+// it instead of reading the body into the returned response.
+func (r *RRFFileManager) roundTrip(ctx context.Context, method, url string, content *sharedBuffer, header http.Header, limit int64, sink func(statusCode int, body io.Reader) error) (*response, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	for attempt := 0; ; attempt++ {
+		resp, err := r.observe(ctx, method, url, func(ctx context.Context) (*response, error) {
+			return r.roundTripOnce(ctx, method, url, content, header, limit, sink)
+		})
+		var serr *StatusError
+		if attempt >= r.busyRetries || !errors.As(err, &serr) || !serr.busy() {
+			return resp, err
+		}
+		wait := r.retryWait(serr)
+		if r.debug {
+			log.Printf("Board busy, retrying %s in %s (%d/%d)", url, wait, attempt+1, r.busyRetries)
+		}
+		if err := sleepCtx(ctx, wait); err != nil {
+			return resp, err
+		}
 	}
-	return body, &duration, nil
 }
 
-// doPostRequest will perform a POST request on the given URL and return
-// the content of the response, a duration on long it tool (including
-// setup of connection) or an error in case something went wrong
-func (r *RRFFileManager) doPostRequest(ctx context.Context, url string, content io.Reader, contentType string) ([]byte, *time.Duration, error) {
+// roundTripOnce performs a single attempt of roundTrip
+func (r *RRFFileManager) roundTripOnce(ctx context.Context, method, url string, content *sharedBuffer, header http.Header, limit int64, sink func(statusCode int, body io.Reader) error) (*response, error) {
 	if r.debug {
-		log.Printf("Doing POST request to %s", url)
+		log.Printf("Doing %s request to %s", method, url)
 	}
+	if r.debug {
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if info.Reused {
+					log.Printf("Reusing connection to %s (idle for %s)", info.Conn.RemoteAddr(), info.IdleTime)
+				} else {
+					log.Printf("Opened new connection to %s", info.Conn.RemoteAddr())
+				}
+			},
+		})
+	}
+	release, err := r.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	start := time.Now()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, content)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if content != nil {
+
+		// Every attempt gets a body of its own which the transport closes once it
+		// is done with it
+		req.Body = content.body()
+		req.ContentLength = int64(content.Len())
+		req.GetBody = func() (io.ReadCloser, error) {
+			return content.body(), nil
+		}
+	}
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if key := r.getSessionKey(); key != 0 {
+		req.Header.Set("X-Session-Key", strconv.FormatUint(key, 10))
 	}
-	req.Header.Set("Content-Type", contentType)
 	if r.debug {
-		dump, _ := httputil.DumpRequestOut(req, true)
+		dump, _ := httputil.DumpRequestOut(req, content != nil)
 		log.Println(string(dump))
 	}
 
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, &TransportError{Err: err}
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if limit > 0 {
+		reader = io.LimitReader(resp.Body, limit+1)
+	}
+	success := resp.StatusCode >= 200 && resp.StatusCode <= 299
+	if success && req.Header.Get("Accept") == jsonContentType {
+		if ct := resp.Header.Get("Content-Type"); !isJSON(ct) {
+			return &response{header: resp.Header, statusCode: resp.StatusCode, duration: time.Since(start)}, &ContentTypeError{ContentType: ct}
+		}
+	}
+	if sink != nil && success {
+		cr := &countingReader{r: reader}
+		err := sink(resp.StatusCode, cr)
+		duration := time.Since(start)
+		if r.debug {
+			log.Printf("Received streamed response (%s, %d bytes in %s)\n%s", resp.Status, cr.n, duration, printHeaders(resp))
+		}
+		res := &response{header: resp.Header, statusCode: resp.StatusCode, duration: duration}
+		if limit > 0 && cr.n > limit {
+			return res, ErrResponseTooLarge
+		}
+		return res, err
+	}
+	body, err := io.ReadAll(reader)
 	duration := time.Since(start)
 	if r.debug {
-		log.Printf("Received response\n%s\n%s", printHeaders(resp), printableBody(body))
+		log.Printf("Received response (%s, %d bytes in %s)\n%s\n%s", resp.Status, len(body), duration, printHeaders(resp), printableBody(body))
 	}
 	if err != nil {
-		return nil, nil, err
+		return nil, &TransportError{Err: err}
+	}
+	res := &response{header: resp.Header, statusCode: resp.StatusCode, duration: duration}
+	if limit > 0 && int64(len(body)) > limit {
+		return res, ErrResponseTooLarge
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		r.signalSessionLost()
 	}
-	return body, &duration, nil
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, &StatusError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	res.body = body
+	return res, nil
 }
 
 func printHeaders(resp *http.Response) string {
@@ -160,44 +401,94 @@ func (r *RRFFileManager) checkError(action string, resp []byte, err error) error
 		return err
 	}
 	if errResp.Err != 0 {
-		return fmt.Errorf("Failed to perform: %s", action)
+		return &ResponseError{Action: action, Code: errResp.Err, Message: errResp.message()}
 	}
 
 	return nil
 }
 
+// now returns the current time used for timestamps sent to the board. It is a
+// variable so tests can fix the clock.
+var now = time.Now
+
+// getTimestamp returns the current time as wall clock of the board's timezone
 func (r *RRFFileManager) getTimestamp() string {
-	return time.Now().Format(TimeFormat)
+	return now().In(r.location).Format(TimeFormat)
+}
+
+// boardTime interprets the timestamp lt as sent by the board in the board's
+// timezone. This is the inverse of getTimestamp.
+func (r *RRFFileManager) boardTime(lt *localTime) {
+	lt.in(r.location)
+}
+
+// Warmup performs a cheap request to resolve the board's host name and open a
+// connection that will be reused by subsequent operations. The content of the
+// response is ignored. It returns the duration of the warm-up.
+func (r *RRFFileManager) Warmup(ctx context.Context) (*time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, duration, err := r.doJSONRequest(ctx, fmt.Sprintf(warmupURL, r.baseURL))
+
+	// Any response at all means the connection is established
+	var serr *StatusError
+	if errors.As(err, &serr) {
+		err = nil
+	}
+	return duration, err
+}
+
+// Do is a low-level method to send a GET request to an arbitrary endpoint like
+// "rr_status" or "rr_gcode" with the given query parameters. It returns the raw
+// response body without interpreting it. Prefer the dedicated methods wherever one
+// exists for the endpoint. params are encoded sorted by key as url.Values does; for
+// endpoints that depend on the order of parameters use DoQuery.
+func (r *RRFFileManager) Do(ctx context.Context, endpoint string, params url.Values) ([]byte, *time.Duration, error) {
+	return r.DoQuery(ctx, endpoint, params.Encode())
+}
+
+// DoQuery is like Do but sends rawQuery as is, e.g. "name=0:/gcodes/a.g&time=..."
+// so the order of parameters is kept. Values must already be query escaped.
+func (r *RRFFileManager) DoQuery(ctx context.Context, endpoint, rawQuery string) ([]byte, *time.Duration, error) {
+	return r.doGetRequest(ctx, fmt.Sprintf(endpointURL, r.baseURL, strings.TrimPrefix(endpoint, "/"), rawQuery))
 }
 
 // Connect establishes a connection to RepRapFirmware
 func (r *RRFFileManager) Connect(ctx context.Context, password string) error {
-	vals := url.Values{}
-	vals.Set("password", password)
-	vals.Set("time", r.getTimestamp())
-	_, _, err := r.doGetRequest(ctx, fmt.Sprintf(connectURL, r.baseURL, vals.Encode()))
+	_, err := r.ConnectResult(ctx, password)
 	return err
 }
 
 // Fileinfo returns information on a given file or an error if the file does not exist
 func (r *RRFFileManager) Fileinfo(ctx context.Context, path string) (*Fileinfo, error) {
-	vals := url.Values{}
-	vals.Set("name", path)
-	body, _, err := r.doGetRequest(ctx, fmt.Sprintf(fileinfoURL, r.baseURL, vals.Encode()))
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	path = cleanPath(path)
+	if f, ok := r.fileinfoCache.get(path); ok {
+		return f, nil
+	}
+	vals := r.query("name", path)
+	body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(fileinfoURL, r.baseURL, vals))
 	if err != nil {
 		return nil, err
 	}
 
 	var f Fileinfo
-	err = json.Unmarshal(body, &f)
+	err = r.decode(body, &f)
 	if err != nil {
 		return nil, err
 	}
 
 	if f.Err != 0 {
-		return nil, ErrFileNotFound
+		return nil, r.mountError(ctx, path, ErrFileNotFound)
 	}
+	r.boardTime(&f.Timestamp)
 
+	r.fileinfoCache.put(path, &f)
 	return &f, nil
 }
 
@@ -205,20 +496,39 @@ func (r *RRFFileManager) Fileinfo(ctx context.Context, path string) (*Fileinfo,
 // If recursive is true it will also populate the field Subdirs of Filelist to contain the full
 // tree.
 func (r *RRFFileManager) Filelist(ctx context.Context, dir string, recursive bool) (*Filelist, error) {
-	fl, err := r.getFullFilelist(ctx, dir, 0)
+	return r.FilelistWithOptions(ctx, dir, FilelistOptions{Recursive: recursive})
+}
+
+// FilelistWithOptions works like Filelist but allows to choose how the entries
+// are sorted. RRF does not support sorting on the board so this is always done
+// after all pages of a listing have been fetched.
+func (r *RRFFileManager) FilelistWithOptions(ctx context.Context, dir string, opts FilelistOptions) (*Filelist, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	dir = r.resolvePath(dir)
+	if err := checkPath(dir); err != nil {
+		return nil, err
+	}
+	fl, err := r.getFullFilelist(ctx, cleanPath(dir), 0)
 	if err != nil {
 		return nil, err
 	}
-	if recursive {
+	if !opts.PreserveOrder {
+		sortFiles(fl.Files, opts.Sort)
+	}
+	fl.indexDirs = r.indexDirs
+	if opts.Recursive {
 		for _, f := range fl.Files {
 			if !f.IsDir() {
-
-				// Directories come first so once we get here we can skip the remaining
-				break
+				continue
+			}
+			subdir := JoinPath(fl.Dir, f.Name)
+			if err := ctx.Err(); err != nil {
+				return nil, &TraversalError{Path: subdir, Err: err}
 			}
-			subfl, err := r.Filelist(ctx, fmt.Sprintf("%s/%s", fl.Dir, f.Name), true)
+			subfl, err := r.FilelistWithOptions(ctx, subdir, opts)
 			if err != nil {
-				return nil, err
+				return nil, traversalError(ctx, subdir, err)
 			}
 			fl.Subdirs = append(fl.Subdirs, subfl)
 		}
@@ -227,104 +537,364 @@ func (r *RRFFileManager) Filelist(ctx context.Context, dir string, recursive boo
 }
 
 func (r *RRFFileManager) getFullFilelist(ctx context.Context, dir string, first uint64) (*Filelist, error) {
-	vals := url.Values{}
-	vals.Set("dir", dir)
-	vals.Set("first", strconv.FormatUint(first, 10))
-	body, _, err := r.doGetRequest(ctx, fmt.Sprintf(filelistURL, r.baseURL, vals.Encode()))
+	fl, err := r.getFilelistPage(ctx, dir, first)
 	if err != nil {
 		return nil, err
 	}
 
+	// Fetch the following pages as long as the response signals there is more. Each
+	// page is checked on its own so a drive removed mid-listing aborts the listing.
+	for next := fl.Next; next > 0; {
+		page, err := r.getFilelistPage(ctx, dir, next)
+		if err != nil {
+			return nil, err
+		}
+		fl.Files = append(fl.Files, page.Files...)
+		next = page.Next
+	}
+	fl.Subdirs = make([]*Filelist, 0)
+	return fl, nil
+}
+
+// getFilelistPage fetches a single page of the listing of dir starting at first
+func (r *RRFFileManager) getFilelistPage(ctx context.Context, dir string, first uint64) (*Filelist, error) {
+	vals := r.query("dir", dir, "first", strconv.FormatUint(first, 10))
+
+	// Listings can be huge so entries are decoded while the response arrives
 	var fl Filelist
-	err = json.Unmarshal(body, &fl)
+	_, err := r.doStreamRequest(ctx, fmt.Sprintf(filelistURL, r.baseURL, vals), func(body io.Reader) error {
+		return r.decodeFilelist(body, &fl)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if fl.Err == errDirectoryNotExist {
+	switch fl.Err {
+	case 0:
+	case errDirectoryNotExist:
 		return nil, ErrDirectoryNotFound
-	}
-	if fl.Err == errDriveNotMounted {
+	case errDriveNotMounted:
+		r.signalSessionLost()
 		return nil, ErrDriveNotMounted
+	default:
+		return nil, &ResponseError{Action: fmt.Sprintf("Listing %s from entry %d", dir, first), Code: fl.Err}
 	}
-
-	// If the response signals there is more to fetch do it recursively
-	if fl.Next > 0 {
-		moreFiles, err := r.getFullFilelist(ctx, dir, fl.Next)
-		if err != nil {
-			return nil, err
-		}
-		fl.Files = append(fl.Files, moreFiles.Files...)
+	for i := range fl.Files {
+		r.boardTime(&fl.Files[i].Timestamp)
 	}
-
-	// Sort folders first and by name
-	sort.SliceStable(fl.Files, func(i, j int) bool {
-
-		// Both same type so compare by name
-		if fl.Files[i].Type == fl.Files[j].Type {
-			return fl.Files[i].Name < fl.Files[j].Name
-		}
-
-		// Different types -> sort folders first
-		return fl.Files[i].Type == typeDirectory
-	})
-	fl.Subdirs = make([]*Filelist, 0)
 	return &fl, nil
 }
 
-// GetFile downloads a file with the given path also returning the duration of this action
+// Download downloads a file with the given path also returning the duration of this action.
+// It returns ErrFileNotFound if the board responds with 404 and a *StatusError for
+// other unsuccessful HTTP status codes.
 func (r *RRFFileManager) Download(ctx context.Context, path string) ([]byte, *time.Duration, error) {
-	vals := url.Values{}
-	vals.Set("name", path)
-	return r.doGetRequest(ctx, fmt.Sprintf(downloadURL, r.baseURL, vals.Encode()))
+	body, _, duration, err := r.DownloadWithType(ctx, path)
+	return body, duration, err
+}
+
+// DownloadWithType works like Download but also returns the content type of the file.
+// This is the Content-Type header sent by the board or, if there is none, the type
+// detected from the content itself.
+func (r *RRFFileManager) DownloadWithType(ctx context.Context, path string) ([]byte, string, *time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return nil, "", nil, err
+	}
+	vals := r.query("name", cleanPath(path))
+	resp, err := r.roundTrip(ctx, http.MethodGet, fmt.Sprintf(downloadURL, r.baseURL, vals), nil, nil, 0, nil)
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+		err = ErrFileNotFound
+	}
+	if err != nil {
+		return nil, "", nil, r.mountError(ctx, path, err)
+	}
+	r.stats.addDownload(int64(len(resp.body)))
+	contentType := resp.header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(resp.body)
+	}
+	return resp.body, contentType, &resp.duration, nil
 }
 
 // Mkdir creates a new directory with the given path
 func (r *RRFFileManager) Mkdir(ctx context.Context, path string) error {
-	vals := url.Values{}
-	vals.Set("dir", path)
-	resp, _, err := r.doGetRequest(ctx, fmt.Sprintf(mkdirURL, r.baseURL, vals.Encode()))
-	return r.checkError(fmt.Sprintf("Mkdir %s", path), resp, err)
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	path = cleanPath(path)
+	vals := r.query("dir", path)
+	resp, _, err := r.doJSONRequest(ctx, fmt.Sprintf(mkdirURL, r.baseURL, vals))
+	return r.writeError(ctx, path, r.checkError(fmt.Sprintf("Mkdir %s", path), resp, err))
+}
+
+// EnsureDir makes sure the directory with the given path exists. Unlike Mkdir it
+// does not fail if the directory is already present.
+func (r *RRFFileManager) EnsureDir(ctx context.Context, path string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	_, err := r.Filelist(ctx, path, false)
+	if err == nil {
+		return nil
+	}
+	if err != ErrDirectoryNotFound {
+		return err
+	}
+	return r.Mkdir(ctx, path)
+}
+
+// MkdirAll creates the directory with the given path along with all missing
+// parent directories. It does not fail if the directory already exists.
+func (r *RRFFileManager) MkdirAll(ctx context.Context, path string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	parent, name := SplitPath(path)
+	if name == "" {
+
+		// The root of a volume always exists
+		return nil
+	}
+	_, err := r.Filelist(ctx, path, false)
+	if err == nil {
+		return nil
+	}
+	if err != ErrDirectoryNotFound {
+		return err
+	}
+	if parent != "" {
+		if err := r.MkdirAll(ctx, parent); err != nil {
+			return err
+		}
+	}
+	return r.Mkdir(ctx, path)
 }
 
 // Move renames or moves a file or directory (only within the same SD card)
 func (r *RRFFileManager) Move(ctx context.Context, oldpath, newpath string) error {
-	vals := url.Values{}
-	vals.Set("old", oldpath)
-	vals.Set("new", newpath)
-	resp, _, err := r.doGetRequest(ctx, fmt.Sprintf(moveURL, r.baseURL, vals.Encode()))
-	return r.checkError(fmt.Sprintf("Rename %s to %s", oldpath, newpath), resp, err)
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	oldpath = r.resolvePath(oldpath)
+	newpath = r.resolvePath(newpath)
+	if err := checkPath(oldpath); err != nil {
+		return err
+	}
+	if err := checkPath(newpath); err != nil {
+		return err
+	}
+	oldpath, newpath = cleanPath(oldpath), cleanPath(newpath)
+	if err := r.checkInUse(ctx, oldpath); err != nil {
+		return err
+	}
+	defer r.fileinfoCache.invalidate(oldpath)
+	defer r.fileinfoCache.invalidate(newpath)
+	vals := r.query("old", oldpath, "new", newpath)
+	resp, _, err := r.doJSONRequest(ctx, fmt.Sprintf(moveURL, r.baseURL, vals))
+	return r.writeError(ctx, oldpath, r.checkError(fmt.Sprintf("Rename %s to %s", oldpath, newpath), resp, err))
+}
+
+// ErrInvalidName is the error returned if a new name for a file or directory
+// is empty or contains path separators
+var ErrInvalidName = errors.New("Invalid name")
+
+// Rename changes only the final element of path to newName keeping it in the same
+// parent directory. newName must not contain any path separators.
+func (r *RRFFileManager) Rename(ctx context.Context, path, newName string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	if newName == "" || newName == "." || newName == ".." || strings.ContainsAny(newName, `/\`) {
+		return ErrInvalidName
+	}
+	dir, _ := SplitPath(path)
+	return r.Move(ctx, path, JoinPath(dir, newName))
 }
 
 // Delete removes the given path. It will fail for non-empty directories.
 func (r *RRFFileManager) Delete(ctx context.Context, path string) error {
-	vals := url.Values{}
-	vals.Set("name", path)
-	resp, _, err := r.doGetRequest(ctx, fmt.Sprintf(deleteURL, r.baseURL, vals.Encode()))
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	path = cleanPath(path)
+	if err := r.checkInUse(ctx, path); err != nil {
+		return err
+	}
+	return r.deletePath(ctx, path)
+}
+
+// deletePath deletes the already cleaned path without checking for an active job
+func (r *RRFFileManager) deletePath(ctx context.Context, path string) error {
+	return r.writeError(ctx, path, r.deleteRequest(ctx, path))
+}
+
+// deleteRequest works like deletePath but returns failures as reported by the
+// board without checking the state of the volume
+func (r *RRFFileManager) deleteRequest(ctx context.Context, path string) error {
+	defer r.fileinfoCache.invalidate(path)
+	vals := r.query("name", path)
+	resp, _, err := r.doJSONRequest(ctx, fmt.Sprintf(deleteURL, r.baseURL, vals))
 	return r.checkError(fmt.Sprintf("Delete %s", path), resp, err)
 }
 
-// Upload uploads a new file to the given path on the SD card
+// ErrResponseTooLarge is the error returned if a response exceeded the size
+// configured with WithMaxResponseSize
+var ErrResponseTooLarge = errors.New("Response too large")
+
+// ErrUploadRetriesExhausted is the error returned if an upload still failed
+// after retrying it as often as configured with WithUploadRetries
+var ErrUploadRetriesExhausted = errors.New("Upload failed after retries")
+
+// Upload uploads a new file to the given path on the SD card.
+// If ctx is cancelled during the upload the returned error is ctx.Err(). The board
+// might be left with a partial file in that case unless the manager was created
+// with WithCancelablePartialCleanup. Concurrent uploads to the same path through
+// the same manager are serialized. Uploads from other managers or processes are
+// not coordinated. The whole file is sent in a single request since rr_upload can
+// neither append to a file nor write at an offset, so setups limiting the size of
+// request bodies cannot receive files larger than that limit.
 func (r *RRFFileManager) Upload(ctx context.Context, path string, content io.Reader) (*time.Duration, error) {
-	content, crc32, err := getCRC32(content)
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, duration, err := r.upload(ctx, path, content)
+	return duration, err
+}
+
+// UploadResult describes what was sent by UploadWithResult
+type UploadResult struct {
+	// Path is the normalized path the file was uploaded to
+	Path string
+	// Bytes is the size of the uploaded content
+	Bytes int64
+	// CRC32 is the hex encoded checksum the board verified the upload against
+	CRC32 string
+	// Duration is how long the successful upload request took
+	Duration time.Duration
+}
+
+// UploadWithResult uploads a new file like Upload but returns what was sent
+// including the CRC32 the board checked the content against.
+func (r *RRFFileManager) UploadWithResult(ctx context.Context, path string, content io.Reader) (*UploadResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	res, _, err := r.upload(ctx, path, content)
 	if err != nil {
 		return nil, err
 	}
-	vals := url.Values{}
-	vals.Set("name", path)
-	vals.Set("time", r.getTimestamp())
-	vals.Set("crc32", crc32)
-	uri := fmt.Sprintf(uploadURL, r.baseURL, vals.Encode())
-	resp, duration, err := r.doPostRequest(ctx, uri, content, "application/octet-stream")
-	return duration, r.checkError(fmt.Sprintf("Uploading file to %s", path), resp, err)
+	return res, nil
+}
+
+// upload performs Upload and UploadWithResult. The duration is returned separately
+// since it is also reported for failed uploads.
+func (r *RRFFileManager) upload(ctx context.Context, path string, content io.Reader) (*UploadResult, *time.Duration, error) {
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return nil, nil, err
+	}
+	path = cleanPath(path)
+
+	// Every attempt reads its own body from buf so it is only reused once the
+	// transport is done with all of them
+	b := getBuffer()
+	_, crc32, err := getCRC32(content, b)
+	buf := newSharedBuffer(b)
+	defer buf.release()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, err
+	}
+	size := int64(buf.Len())
+	unlock, err := r.uploadLocks.lock(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	header := http.Header{"Content-Type": {r.uploadContentType(path)}}
+	for attempt := 0; ; attempt++ {
+		duration, err := r.postFile(ctx, path, buf, crc32, header)
+		if err == nil {
+			res := &UploadResult{Path: path, Bytes: size, CRC32: crc32}
+			if duration != nil {
+				res.Duration = *duration
+			}
+			return res, duration, nil
+		}
+		var rerr *ResponseError
+		if !errors.As(err, &rerr) || rerr.Code != errUploadFailed {
+			return nil, duration, r.writeError(ctx, path, err)
+		}
+		if attempt >= r.uploadRetries {
+
+			// The cause is only diagnosed once so failed attempts cost no extra requests
+			err = r.writeError(ctx, path, err)
+			if attempt == 0 {
+				return nil, duration, err
+			}
+			return nil, duration, fmt.Errorf("%w: %w", ErrUploadRetriesExhausted, err)
+		}
+		if r.debug {
+			log.Printf("Upload to %s failed, retrying (%d/%d)", path, attempt+1, r.uploadRetries)
+		}
+	}
+}
+
+// postFile sends content to rr_upload for the given path using crc32 as
+// checksum of the file as it should end up on the board. Failures are returned
+// as reported by the board without checking the state of the volume.
+func (r *RRFFileManager) postFile(ctx context.Context, path string, content *sharedBuffer, crc32 string, header http.Header) (*time.Duration, error) {
+	defer r.fileinfoCache.invalidate(path)
+	vals := r.query("name", path, "time", r.getTimestamp(), "crc32", crc32)
+	uri := fmt.Sprintf(uploadURL, r.baseURL, vals)
+	size := content.Len()
+	resp, duration, err := r.doPostRequest(ctx, uri, content, header)
+	if err != nil && ctx.Err() != nil {
+		if r.partialCleanup {
+			r.cleanupPartial(ctx, path)
+		}
+		return nil, ctx.Err()
+	}
+	if err = r.checkError(fmt.Sprintf("Uploading file to %s", path), resp, err); err != nil {
+		return duration, err
+	}
+	r.stats.addUpload(size)
+	return duration, nil
+}
+
+// cleanupPartial deletes what might be left of a cancelled upload to path. Since
+// ctx is already done this uses a detached context with its own timeout.
+func (r *RRFFileManager) cleanupPartial(ctx context.Context, path string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+	defer cancel()
+	if err := r.Delete(ctx, path); err != nil && r.debug {
+		log.Printf("Failed to remove partial upload %s: %s", path, err)
+	}
 }
 
-func getCRC32(content io.Reader) (io.Reader, string, error) {
+// getCRC32 reads content into buf and returns a reader over it along with the
+// hex encoded CRC32 sum of the content
+func getCRC32(content io.Reader, buf *bytes.Buffer) (*bytes.Reader, string, error) {
 
 	// Slurp the io.Reader back into a byte slice
-	b, err := io.ReadAll(content)
-	if err != nil {
+	if _, err := buf.ReadFrom(content); err != nil {
 		return nil, "", err
 	}
+	b := buf.Bytes()
 	// Calculate CRC32 with IEEE polynomials
 	c := crc32.ChecksumIEEE(b)
 
@@ -0,0 +1,27 @@
+package librfm
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newTestManager returns a manager talking to a test server serving handler
+func newTestManager(t testing.TB, handler http.HandlerFunc, opts ...Option) *RRFFileManager {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(host, p, false, opts...)
+	t.Cleanup(func() { r.Close() })
+	return r
+}
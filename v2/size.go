@@ -0,0 +1,42 @@
+package librfm
+
+import "fmt"
+
+var (
+	siUnits  = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	iecUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+)
+
+// formatSize formats size as a human readable string using the given base
+// (1000 for SI or 1024 for IEC) and its unit names
+func formatSize(size uint64, base uint64, units []string) string {
+	if size < base {
+		return fmt.Sprintf("%d %s", size, units[0])
+	}
+	div, exp := base, 1
+	for n := size / base; n >= base && exp < len(units)-1; n /= base {
+		div *= base
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(size)/float64(div), units[exp])
+}
+
+// HumanSize returns the size of the file formatted with SI units, e.g. "4.7 MB"
+func (f *File) HumanSize() string {
+	return formatSize(f.Size, 1000, siUnits)
+}
+
+// HumanSizeIEC returns the size of the file formatted with IEC units, e.g. "4.5 MiB"
+func (f *File) HumanSizeIEC() string {
+	return formatSize(f.Size, 1024, iecUnits)
+}
+
+// HumanSize returns the size of the file formatted with SI units, e.g. "4.7 MB"
+func (f *Fileinfo) HumanSize() string {
+	return formatSize(f.Size, 1000, siUnits)
+}
+
+// HumanSizeIEC returns the size of the file formatted with IEC units, e.g. "4.5 MiB"
+func (f *Fileinfo) HumanSizeIEC() string {
+	return formatSize(f.Size, 1024, iecUnits)
+}
@@ -0,0 +1,44 @@
+package librfm
+
+import "sync/atomic"
+
+// ManagerStats holds the transfer totals of a manager since it was created
+type ManagerStats struct {
+	// Uploads is the number of successful uploads
+	Uploads uint64
+	// BytesUploaded is the total size of all successful uploads
+	BytesUploaded uint64
+	// Downloads is the number of successful downloads
+	Downloads uint64
+	// BytesDownloaded is the total size of all downloaded content
+	BytesDownloaded uint64
+}
+
+// transferStats are the counters behind ManagerStats
+type transferStats struct {
+	uploads         atomic.Uint64
+	bytesUploaded   atomic.Uint64
+	downloads       atomic.Uint64
+	bytesDownloaded atomic.Uint64
+}
+
+func (s *transferStats) addUpload(n int) {
+	s.uploads.Add(1)
+	s.bytesUploaded.Add(uint64(n))
+}
+
+func (s *transferStats) addDownload(n int64) {
+	s.downloads.Add(1)
+	s.bytesDownloaded.Add(uint64(n))
+}
+
+// Stats returns the number of files and bytes transferred by this manager so far.
+// It is safe to call concurrently with running transfers.
+func (r *RRFFileManager) Stats() ManagerStats {
+	return ManagerStats{
+		Uploads:         r.stats.uploads.Load(),
+		BytesUploaded:   r.stats.bytesUploaded.Load(),
+		Downloads:       r.stats.downloads.Load(),
+		BytesDownloaded: r.stats.bytesDownloaded.Load(),
+	}
+}
@@ -0,0 +1,129 @@
+package librfm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// ErrStatusUnavailable is the error returned by Status if the board does not offer
+// the legacy rr_status endpoint, e.g. because it runs a firmware that removed it
+var ErrStatusUnavailable = errors.New("Legacy status not available")
+
+// ErrInvalidStatusLevel is the error returned by Status for levels other than 1 to 3
+var ErrInvalidStatusLevel = errors.New("Invalid status level")
+
+// Status is the machine state returned by the legacy rr_status endpoint of RRF 2.x.
+// Boards running RRF 3 should be queried through the object model instead.
+type Status struct {
+	// Status is the single letter machine state, e.g. "I" for idle or "P" for printing
+	Status string
+	// Coords holds the positions of axes and extruders
+	Coords StatusCoords
+	// CurrentTool is the number of the selected tool or -1 if none is selected
+	CurrentTool int
+	// Temps holds the temperatures of bed and heaters
+	Temps StatusTemps
+	// Time is the uptime of the board in seconds
+	Time float64
+	// CurrentLayer is the layer being printed (level 3 only)
+	CurrentLayer int
+	// FractionPrinted is the percentage of the job printed so far (level 3 only)
+	FractionPrinted float64
+	// PrintDuration is the time in seconds the job has been running (level 3 only)
+	PrintDuration float64
+	// File is the path of the job being printed. It is not part of rr_status and only
+	// filled at level 3 while a job is running.
+	File string `json:"-"`
+}
+
+// StatusCoords holds the positions of axes and extruders reported by rr_status
+type StatusCoords struct {
+	// AxesHomed has a one for every homed axis
+	AxesHomed []int
+	// XYZ are the user positions of the axes in mm
+	XYZ []float64 `json:"xyz"`
+	// Machine are the machine positions of the axes in mm
+	Machine []float64
+	// Extr are the positions of the extruders in mm
+	Extr []float64
+}
+
+// StatusTemps holds the temperatures reported by rr_status
+type StatusTemps struct {
+	// Bed is the state of the bed heater or nil if there is none
+	Bed *HeaterStatus
+	// Current are the current temperatures of all heaters in °C
+	Current []float64
+	// State are the states of all heaters, 0 is off, 1 standby, 2 active, 3 fault
+	State []int
+}
+
+// HeaterStatus is the state of a single heater reported by rr_status
+type HeaterStatus struct {
+	// Current is its current temperature in °C
+	Current float64
+	// Active is its active temperature in °C
+	Active float64
+	// Standby is its standby temperature in °C
+	Standby float64
+	// State is 0 for off, 1 for standby, 2 for active and 3 for fault
+	State int
+	// Heater is the number of the heater
+	Heater int
+}
+
+// IsPrinting returns true if a job is running, paused or being simulated
+func (s *Status) IsPrinting() bool {
+	switch s.Status {
+	case "P", "A", "D", "R", "M":
+		return true
+	}
+	return false
+}
+
+type currentFileResponse struct {
+	Err      ErrorCode
+	FileName string
+}
+
+// Status queries the legacy rr_status endpoint with the given level between 1 and 3
+// where higher levels add more details. It returns ErrStatusUnavailable if the board
+// does not know the endpoint.
+func (r *RRFFileManager) Status(ctx context.Context, level int) (*Status, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if level < 1 || level > 3 {
+		return nil, ErrInvalidStatusLevel
+	}
+	vals := r.query("type", strconv.Itoa(level))
+	body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(statusURL, r.baseURL, vals))
+	var serr *StatusError
+	if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+		return nil, ErrStatusUnavailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Status
+	if err := json.Unmarshal(body, &s); err != nil || s.Status == "" {
+		return nil, ErrStatusUnavailable
+	}
+	if level == 3 && s.IsPrinting() {
+
+		// Without a name rr_fileinfo describes the file being printed
+		body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(fileinfoURL, r.baseURL, ""))
+		if err != nil {
+			return nil, err
+		}
+		var f currentFileResponse
+		if err := json.Unmarshal(body, &f); err == nil && f.Err == 0 {
+			s.File = f.FileName
+		}
+	}
+	return &s, nil
+}
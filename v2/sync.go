@@ -0,0 +1,200 @@
+package librfm
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mtimeTolerance accounts for FAT storing modification times with a
+// resolution of two seconds
+const mtimeTolerance = 2 * time.Second
+
+// SyncOptions control the behavior of Sync
+type SyncOptions struct {
+	// Delete removes remote files and directories that do not exist locally
+	Delete bool
+}
+
+// SyncReport summarizes what Sync did
+type SyncReport struct {
+	// Uploaded is the number of new or changed files that were uploaded
+	Uploaded int
+	// Skipped is the number of files that were already up to date
+	Skipped int
+	// Deleted is the number of remote files or directories that were removed
+	Deleted int
+}
+
+// Sync mirrors the local directory localDir to remoteDir on the board. Missing
+// directories are created and files are only uploaded if they do not exist remotely
+// or differ in size or are newer locally. If opts.Delete is set remote entries not
+// present locally are removed afterwards.
+func (r *RRFFileManager) Sync(ctx context.Context, localDir, remoteDir string, opts SyncOptions) (SyncReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	remoteDir = r.resolvePath(remoteDir)
+	var report SyncReport
+	remoteDir = cleanPath(remoteDir)
+
+	// Index the remote tree by path relative to remoteDir
+	remote := make(map[string]File)
+	fl, err := r.Filelist(ctx, remoteDir, true)
+	switch {
+	case err == ErrDirectoryNotFound:
+		if err := r.MkdirAll(ctx, remoteDir); err != nil {
+			return report, err
+		}
+	case err != nil:
+		return report, err
+	default:
+		fl.Walk(func(dir string, f File) error {
+			rel := strings.TrimPrefix(strings.TrimPrefix(dir, fl.Dir), "/")
+			remote[path.Join(rel, f.Name)] = f
+			return nil
+		})
+	}
+
+	seen := make(map[string]bool)
+	err = filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+		target := JoinPath(remoteDir, rel)
+		rf, exists := remote[rel]
+
+		if d.IsDir() {
+			if exists && rf.IsDir() {
+				return nil
+			}
+			return r.Mkdir(ctx, target)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if exists && compareLocal(info, &rf) == "" {
+			report.Skipped++
+			return nil
+		}
+		if err := r.uploadFile(ctx, p, target); err != nil {
+			return err
+		}
+		report.Uploaded++
+		return nil
+	})
+	if err != nil || !opts.Delete {
+		return report, err
+	}
+
+	// Remove what is not present locally. Sorting makes sure directories are
+	// handled before their contents which then do not need to be deleted separately.
+	rels := make([]string, 0, len(remote))
+	for rel := range remote {
+		if !seen[rel] {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	deleted := make(map[string]bool)
+	for _, rel := range rels {
+		if hasDeletedParent(rel, deleted) {
+			continue
+		}
+		target := JoinPath(remoteDir, rel)
+		if rf := remote[rel]; rf.IsDir() {
+			err = r.DeleteRecursive(ctx, target)
+		} else {
+			err = r.Delete(ctx, target)
+		}
+		if err != nil {
+			return report, err
+		}
+		deleted[rel] = true
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// NeedsUpload compares the local file at localPath with remotePath and returns
+// whether it has to be uploaded along with the reason, i.e. "missing remotely",
+// "size differs", "newer locally" or "not a file remotely". Nothing is downloaded:
+// size and modification time are taken from the listing of the remote parent
+// directory. RRF does not expose checksums of stored files so content that changed
+// without changing size or modification time is not detected.
+func (r *RRFFileManager) NeedsUpload(ctx context.Context, localPath, remotePath string) (bool, string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	remotePath = r.resolvePath(remotePath)
+	if err := checkPath(remotePath); err != nil {
+		return false, "", err
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false, "", err
+	}
+	parent, name := SplitPath(remotePath)
+	if name == "" {
+
+		// The root of a volume is always a directory
+		return true, "not a file remotely", nil
+	}
+	fl, err := r.Filelist(ctx, parent, false)
+	if err == ErrDirectoryNotFound {
+		return true, "missing remotely", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	// Directories are not part of the index by default so the listing is searched directly
+	for i := range fl.Files {
+		if fl.Files[i].Name == name {
+			reason := compareLocal(info, &fl.Files[i])
+			return reason != "", reason, nil
+		}
+	}
+	return true, "missing remotely", nil
+}
+
+// compareLocal compares a local file with its remote counterpart and returns
+// the reason why it needs to be uploaded or an empty string if it is up to date
+func compareLocal(info os.FileInfo, remote *File) string {
+	if !remote.IsFile() {
+		return "not a file remotely"
+	}
+	if uint64(info.Size()) != remote.Size {
+		return "size differs"
+	}
+	if info.ModTime().After(remote.Date().Add(mtimeTolerance)) {
+		return "newer locally"
+	}
+	return ""
+}
+
+// hasDeletedParent checks if any parent directory of rel is in deleted
+func hasDeletedParent(rel string, deleted map[string]bool) bool {
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if deleted[dir] {
+			return true
+		}
+	}
+	return false
+}
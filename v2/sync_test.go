@@ -0,0 +1,190 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNeedsUpload(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "a.g")
+	if err := os.WriteFile(local, []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(local, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Query().Get("dir") {
+		case "0:/gcodes":
+			fmt.Fprint(w, `{"dir":"0:/gcodes","first":0,"files":[`+
+				`{"type":"d","name":"sub","size":0,"date":"2024-06-01T12:00:00"},`+
+				`{"type":"f","name":"same.g","size":5,"date":"2024-06-01T12:00:01"},`+
+				`{"type":"f","name":"big.g","size":9,"date":"2024-06-01T12:00:00"},`+
+				`{"type":"f","name":"old.g","size":5,"date":"2024-01-01T12:00:00"}],"next":0}`)
+		default:
+			fmt.Fprint(w, `{"err":2}`)
+		}
+	}, WithLocation(time.UTC))
+
+	tests := []struct {
+		remote string
+		needs  bool
+		reason string
+	}{
+		{"0:/gcodes/missing.g", true, "missing remotely"},
+		{"0:/nodir/a.g", true, "missing remotely"},
+		{"0:/gcodes/sub", true, "not a file remotely"},
+		{"0:/", true, "not a file remotely"},
+		{"0:/gcodes/big.g", true, "size differs"},
+		{"0:/gcodes/old.g", true, "newer locally"},
+		{"0:/gcodes/same.g", false, ""},
+	}
+	for _, tt := range tests {
+		needs, reason, err := r.NeedsUpload(context.Background(), local, tt.remote)
+		if err != nil {
+			t.Errorf("NeedsUpload(%q): %v", tt.remote, err)
+			continue
+		}
+		if needs != tt.needs || reason != tt.reason {
+			t.Errorf("NeedsUpload(%q) = %v, %q; want %v, %q", tt.remote, needs, reason, tt.needs, tt.reason)
+		}
+	}
+}
+
+// fakeCard keeps the contents of an SD card in memory and serves them like RRF
+// without object model. entries maps full paths to their size or -1 for directories.
+type fakeCard struct {
+	mu      sync.Mutex
+	entries map[string]int
+	changes []string
+}
+
+func (c *fakeCard) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q := req.URL.Query()
+	switch req.URL.Path {
+	case "/rr_filelist":
+		dir := q.Get("dir")
+		if size, ok := c.entries[dir]; !ok || size >= 0 {
+			fmt.Fprint(w, `{"err":2}`)
+			return
+		}
+		var files []string
+		for p, size := range c.entries {
+			parent, name := SplitPath(p)
+			if parent != dir || name == "" {
+				continue
+			}
+			typ := "f"
+			if size < 0 {
+				typ, size = "d", 0
+			}
+			files = append(files, fmt.Sprintf(`{"type":%q,"name":%q,"size":%d,"date":"2024-06-01T12:00:00"}`, typ, name, size))
+		}
+		sort.Strings(files)
+		fmt.Fprintf(w, `{"dir":%q,"first":0,"files":[%s],"next":0}`, dir, strings.Join(files, ","))
+	case "/rr_mkdir":
+		dir := q.Get("dir")
+		if size, ok := c.entries[ParentDir(dir)]; !ok || size >= 0 {
+			fmt.Fprint(w, `{"err":1}`)
+			return
+		}
+		c.entries[dir] = -1
+		c.changes = append(c.changes, "mkdir "+dir)
+		fmt.Fprint(w, `{"err":0}`)
+	case "/rr_upload":
+		b, _ := io.ReadAll(req.Body)
+		c.entries[q.Get("name")] = len(b)
+		c.changes = append(c.changes, "upload "+q.Get("name"))
+		fmt.Fprint(w, `{"err":0}`)
+	case "/rr_delete":
+		delete(c.entries, q.Get("name"))
+		c.changes = append(c.changes, "delete "+q.Get("name"))
+		fmt.Fprint(w, `{"err":0}`)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// writeLocal creates the file rel below dir with content and a fixed modification time
+func writeLocal(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	local := t.TempDir()
+	writeLocal(t, local, "same.g", "12345")
+	writeLocal(t, local, "changed.g", "123")
+	writeLocal(t, local, "sub/new.g", "1")
+
+	card := &fakeCard{entries: map[string]int{
+		"0:/":                    -1,
+		"0:/macros":              -1,
+		"0:/macros/same.g":       5,
+		"0:/macros/changed.g":    9,
+		"0:/macros/stale.g":      1,
+		"0:/macros/old":          -1,
+		"0:/macros/old/inside.g": 1,
+	}}
+	r := newTestManager(t, card.ServeHTTP, WithLocation(time.UTC))
+
+	report, err := r.Sync(context.Background(), local, "0:/macros", SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncReport{Uploaded: 2, Skipped: 1, Deleted: 2}); report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	want := []string{
+		"upload 0:/macros/changed.g",
+		"mkdir 0:/macros/sub",
+		"upload 0:/macros/sub/new.g",
+		"delete 0:/macros/old/inside.g",
+		"delete 0:/macros/old",
+		"delete 0:/macros/stale.g",
+	}
+	if fmt.Sprint(card.changes) != fmt.Sprint(want) {
+		t.Errorf("changes = %q, want %q", card.changes, want)
+	}
+}
+
+func TestSyncCreatesMissingParents(t *testing.T) {
+	local := t.TempDir()
+	writeLocal(t, local, "a.g", "1")
+	card := &fakeCard{entries: map[string]int{"0:/": -1, "0:/macros": -1}}
+	r := newTestManager(t, card.ServeHTTP)
+
+	report, err := r.Sync(context.Background(), local, "0:/macros/new/sub", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Uploaded != 1 {
+		t.Errorf("report = %+v, want one upload", report)
+	}
+	want := []string{"mkdir 0:/macros/new", "mkdir 0:/macros/new/sub", "upload 0:/macros/new/sub/a.g"}
+	if fmt.Sprint(card.changes) != fmt.Sprint(want) {
+		t.Errorf("changes = %q, want %q", card.changes, want)
+	}
+}
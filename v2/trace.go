@@ -0,0 +1,166 @@
+package librfm
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the breakdown of a request's duration collected with WithTracing.
+// Phases that did not happen, e.g. DNS and Connect on a reused connection, are zero.
+type Timings struct {
+	// DNS is the time spent resolving the host name
+	DNS time.Duration
+
+	// Connect is the time spent establishing the TCP connection
+	Connect time.Duration
+
+	// TLS is the time spent on the TLS handshake
+	TLS time.Duration
+
+	// FirstByte is the time from sending the request until the first byte
+	// of the response arrived, i.e. mostly the board's processing time
+	FirstByte time.Duration
+
+	// Reused is true if the request was sent over an idle keep-alive connection.
+	// If it is false for most requests the board or a proxy closes connections
+	// which makes every request pay for connection setup.
+	Reused bool
+}
+
+// RequestEvent describes a finished request and is passed to the hook set with
+// WithRequestHook
+type RequestEvent struct {
+	// Method is the HTTP method of the request
+	Method string
+
+	// URL is the requested URL
+	URL string
+
+	// StatusCode is the HTTP status code of the response or zero if none was received
+	StatusCode int
+
+	// Duration is the total time the request took
+	Duration time.Duration
+
+	// Err is the error the request failed with, if any
+	Err error
+
+	// Timings holds the breakdown of Duration if tracing is enabled with WithTracing
+	Timings *Timings
+}
+
+// WithRequestHook sets a function called after each request sent to the board, e.g.
+// to record metrics. It is called synchronously so it should return quickly.
+func WithRequestHook(hook func(RequestEvent)) Option {
+	return func(r *RRFFileManager) {
+		r.requestHook = hook
+	}
+}
+
+// WithTracing enables collecting DNS, connect, TLS and first byte timings of each
+// request which are passed to the hook set with WithRequestHook. It is disabled by
+// default since tracing adds a small overhead to every request.
+func WithTracing(enabled bool) Option {
+	return func(r *RRFFileManager) {
+		r.tracing = enabled
+	}
+}
+
+// tracer collects Timings through an httptrace.ClientTrace
+type tracer struct {
+	mu                            sync.Mutex
+	dnsStart, connStart, tlsStart time.Time
+	wrote                         time.Time
+	t                             Timings
+}
+
+// withTrace returns ctx with a ClientTrace attached that records into tr
+func (tr *tracer) withTrace(ctx context.Context) context.Context {
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tr.mu.Lock()
+			tr.dnsStart = time.Now()
+			tr.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tr.mu.Lock()
+			tr.t.DNS = since(tr.dnsStart)
+			tr.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			tr.mu.Lock()
+			tr.connStart = time.Now()
+			tr.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			tr.mu.Lock()
+			tr.t.Connect = since(tr.connStart)
+			tr.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			tr.mu.Lock()
+			tr.tlsStart = time.Now()
+			tr.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tr.mu.Lock()
+			tr.t.TLS = since(tr.tlsStart)
+			tr.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tr.mu.Lock()
+			tr.t.Reused = info.Reused
+			tr.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			tr.mu.Lock()
+			tr.wrote = time.Now()
+			tr.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			tr.mu.Lock()
+			tr.t.FirstByte = since(tr.wrote)
+			tr.mu.Unlock()
+		},
+	})
+}
+
+// timings returns a copy of the collected timings
+func (tr *tracer) timings() *Timings {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	t := tr.t
+	return &t
+}
+
+// observe performs a roundTripOnce attempt and reports it to the request hook
+func (r *RRFFileManager) observe(ctx context.Context, method, url string, send func(context.Context) (*response, error)) (*response, error) {
+	if r.requestHook == nil {
+		return send(ctx)
+	}
+	var tr *tracer
+	if r.tracing {
+		tr = &tracer{}
+		ctx = tr.withTrace(ctx)
+	}
+	start := time.Now()
+	resp, err := send(ctx)
+	ev := RequestEvent{Method: method, URL: url, Duration: time.Since(start), Err: err}
+	if resp != nil {
+		ev.StatusCode = resp.statusCode
+	}
+	if tr != nil {
+		ev.Timings = tr.timings()
+	}
+	r.requestHook(ev)
+	return resp, err
+}
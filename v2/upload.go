@@ -0,0 +1,191 @@
+package librfm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrVerificationFailed is the error returned if the content read back from
+// the board after an upload does not match what was sent
+var ErrVerificationFailed = errors.New("Uploaded content does not match")
+
+// UploadVerified uploads a new file to the given path and afterwards downloads it
+// again to compare its SHA256 sum with the one of content. RRF only checks uploads
+// by CRC32 so this gives a stronger guarantee at the cost of a second transfer.
+func (r *RRFFileManager) UploadVerified(ctx context.Context, path string, content io.Reader) (*time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	b, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := r.Upload(ctx, path, bytes.NewReader(b))
+	if err != nil {
+		return duration, err
+	}
+	uploaded, _, err := r.Download(ctx, path)
+	if err != nil {
+		return duration, err
+	}
+	if sha256.Sum256(b) != sha256.Sum256(uploaded) {
+		return duration, ErrVerificationFailed
+	}
+	return duration, nil
+}
+
+// UploadGzip compresses content with gzip before uploading it to the given path and
+// marks the request with "Content-Encoding: gzip". RepRapFirmware itself does not
+// decompress uploads, so this is only useful if a proxy in front of the board
+// decompresses the request body before passing it on. The CRC32 sent along is the
+// one of the uncompressed content.
+func (r *RRFFileManager) UploadGzip(ctx context.Context, path string, content io.Reader) (*time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	path = cleanPath(path)
+	raw := getBuffer()
+	defer putBuffer(raw)
+	content, crc32, err := getCRC32(content, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// The compressed content is read by the transport which might close the body
+	// only after postFile returned
+	buf := newSharedBuffer(getBuffer())
+	defer buf.release()
+	zw := gzip.NewWriter(buf.buf)
+	if _, err := io.Copy(zw, content); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	unlock, err := r.uploadLocks.lock(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	duration, err := r.postFile(ctx, path, buf, crc32, http.Header{
+		"Content-Type":     {r.uploadContentType(path)},
+		"Content-Encoding": {"gzip"},
+	})
+	return duration, r.writeError(ctx, path, err)
+}
+
+// UploadUnique uploads content to the given path without overwriting an existing file.
+// If the path is already taken an incrementing suffix is inserted before the extension
+// the way browsers name downloads, e.g. "job (1).gcode". The path finally used is
+// returned along with the upload's duration. Checking and uploading are not atomic so
+// a concurrent upload to the same name can still be overwritten.
+func (r *RRFFileManager) UploadUnique(ctx context.Context, path string, content io.Reader) (string, *time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	if err := checkPath(path); err != nil {
+		return "", nil, err
+	}
+	dir, name := SplitPath(cleanPath(path))
+	if name == "" {
+		return "", nil, ErrInvalidPath
+	}
+	base, ext := splitExt(name)
+	finalPath := JoinPath(dir, name)
+	for i := 1; ; i++ {
+		exists, err := r.Exists(ctx, finalPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if !exists {
+			break
+		}
+		finalPath = JoinPath(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+	duration, err := r.Upload(ctx, finalPath, content)
+	return finalPath, duration, err
+}
+
+// splitExt splits name into base name and extension including the dot. A leading
+// dot as in ".config" is not treated as extension.
+func splitExt(name string) (string, string) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return name, ""
+	}
+	return name[:i], name[i:]
+}
+
+// ErrAlreadyExists is the error returned by UploadNoClobber if the target exists
+var ErrAlreadyExists = errors.New("File already exists")
+
+// UploadNoClobber uploads content to the given path only if there is no file or
+// directory with that path yet and returns ErrAlreadyExists otherwise. RRF offers no
+// exclusive create so the check and the upload are not atomic.
+func (r *RRFFileManager) UploadNoClobber(ctx context.Context, path string, content io.Reader) (*time.Duration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	path = r.resolvePath(path)
+	exists, err := r.Exists(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyExists
+	}
+	return r.Upload(ctx, path, content)
+}
+
+// defaultUploadContentType is the Content-Type of uploads RRF expects
+const defaultUploadContentType = "application/octet-stream"
+
+// gcodeExtensions are the extensions of G-code files as known to RRF
+var gcodeExtensions = map[string]bool{".g": true, ".gc": true, ".gco": true, ".gcode": true}
+
+// WithUploadContentType sets a function returning the Content-Type sent with an
+// upload to the given path, e.g. ContentTypeByExtension. RRF ignores the header but
+// proxies or logging middleware in front of the board might make use of it. By
+// default every upload is sent as application/octet-stream.
+func WithUploadContentType(fn func(path string) string) Option {
+	return func(r *RRFFileManager) {
+		r.contentTypeFunc = fn
+	}
+}
+
+// ContentTypeByExtension infers the content type of path from its extension. G-code
+// files are reported as text/x-gcode, unknown extensions as application/octet-stream.
+func ContentTypeByExtension(path string) string {
+	_, name := SplitPath(path)
+	_, ext := splitExt(name)
+	ext = strings.ToLower(ext)
+	if gcodeExtensions[ext] {
+		return "text/x-gcode"
+	}
+	if ct := mime.TypeByExtension(ext); ct != "" {
+		return ct
+	}
+	return defaultUploadContentType
+}
+
+// uploadContentType returns the Content-Type to send with an upload to path
+func (r *RRFFileManager) uploadContentType(path string) string {
+	if r.contentTypeFunc == nil {
+		return defaultUploadContentType
+	}
+	if ct := r.contentTypeFunc(path); ct != "" {
+		return ct
+	}
+	return defaultUploadContentType
+}
@@ -0,0 +1,225 @@
+package librfm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrVolumeBusy is the error returned if a volume cannot be unmounted because
+// files on it are in use, e.g. by a running print
+var ErrVolumeBusy = errors.New("Volume is busy")
+
+// ErrVolumeStateUnchanged is the error returned if mounting or unmounting a volume
+// did not have the desired effect
+var ErrVolumeStateUnchanged = errors.New("Volume state did not change")
+
+// ErrReadOnly is the error returned if a write operation failed because the
+// volume is mounted read-only, e.g. due to the write-protect switch of the card
+var ErrReadOnly = errors.New("Volume is read-only")
+
+// volumeModel resembles an entry of the volumes array of the object model
+type volumeModel struct {
+	Mounted bool
+	// WriteSupported is only reported by some firmware versions
+	WriteSupported *bool
+	// FreeSpace and TotalSpace are null if the volume is not mounted
+	FreeSpace  *uint64
+	TotalSpace *uint64
+}
+
+// volumeOf returns the volume specifier of path defaulting to the first volume
+func volumeOf(path string) string {
+	volume, _ := splitVolume(cleanPath(path))
+	if volume == "" {
+		return "0:"
+	}
+	return volume
+}
+
+// volumeIndex returns the numeric index of the volume containing path
+func volumeIndex(path string) int {
+	i, _ := strconv.Atoi(strings.TrimSuffix(volumeOf(path), ":"))
+	return i
+}
+
+// CanWrite checks whether the given volume is mounted and can be written to. This
+// uses the object model so it is not available on firmware versions without it.
+func (r *RRFFileManager) CanWrite(ctx context.Context, volume int) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var v volumeModel
+	if err := r.getModel(ctx, fmt.Sprintf("volumes[%d]", volume), "", &v); err != nil {
+		return false, err
+	}
+	return v.Mounted && (v.WriteSupported == nil || *v.WriteSupported), nil
+}
+
+// volumeMounted checks if the volume containing path is mounted by listing
+// the first page of its root directory
+func (r *RRFFileManager) volumeMounted(ctx context.Context, path string) (bool, error) {
+	vals := r.query("dir", volumeOf(path)+"/", "first", "0")
+	body, _, err := r.doJSONRequest(ctx, fmt.Sprintf(filelistURL, r.baseURL, vals))
+	if err != nil {
+		return false, err
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return false, err
+	}
+	return errResp.Err != errDriveNotMounted, nil
+}
+
+// mountError replaces err by ErrDriveNotMounted if the board reported a failure
+// for path and this was caused by its volume not being mounted. Only errors the
+// board reported for path itself, i.e. a *ResponseError or ErrFileNotFound, are
+// diagnosed. Others are returned unchanged.
+func (r *RRFFileManager) mountError(ctx context.Context, path string, err error) error {
+	return r.volumeError(ctx, path, err, false)
+}
+
+// writeError works like mountError and additionally replaces err by ErrReadOnly if
+// the failure was caused by the volume containing path being read-only
+func (r *RRFFileManager) writeError(ctx context.Context, path string, err error) error {
+	return r.volumeError(ctx, path, err, true)
+}
+
+// volumeError performs mountError and writeError. The state of the volume is read
+// from the object model with a single request. Only on firmware without object
+// model the root directory of the volume is listed instead.
+func (r *RRFFileManager) volumeError(ctx context.Context, path string, err error, write bool) error {
+	var rerr *ResponseError
+	if (!errors.As(err, &rerr) && err != ErrFileNotFound) || ctx.Err() != nil {
+		return err
+	}
+	var v volumeModel
+	if merr := r.getModel(ctx, fmt.Sprintf("volumes[%d]", volumeIndex(path)), "", &v); merr != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		if mounted, merr := r.volumeMounted(ctx, path); merr != nil || mounted {
+			return err
+		}
+		r.signalSessionLost()
+		return ErrDriveNotMounted
+	}
+	if !v.Mounted {
+		r.signalSessionLost()
+		return ErrDriveNotMounted
+	}
+	if write && v.WriteSupported != nil && !*v.WriteSupported {
+		return ErrReadOnly
+	}
+	return err
+}
+
+// MountVolume mounts the SD card in the given volume using M21 and verifies that
+// it is mounted afterwards
+func (r *RRFFileManager) MountVolume(ctx context.Context, volume int) error {
+	return r.setMounted(ctx, volume, true)
+}
+
+// UnmountVolume unmounts the SD card in the given volume using M22 and verifies
+// that it is not mounted afterwards. It returns ErrVolumeBusy if the card cannot
+// be unmounted because files on it are in use.
+func (r *RRFFileManager) UnmountVolume(ctx context.Context, volume int) error {
+	return r.setMounted(ctx, volume, false)
+}
+
+func (r *RRFFileManager) setMounted(ctx context.Context, volume int, mount bool) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	code := fmt.Sprintf("M22 P%d", volume)
+	if mount {
+		code = fmt.Sprintf("M21 P%d", volume)
+	}
+	reply, err := r.RunGCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	lower := strings.ToLower(reply)
+	if !mount && (strings.Contains(lower, "busy") || strings.Contains(lower, "open")) {
+		return ErrVolumeBusy
+	}
+	if err := gcodeError(code, reply); err != nil {
+		return err
+	}
+
+	// The object model might lag behind the reply so the state is checked a few times
+	deadline := time.Now().Add(replyTimeout)
+	for {
+		mounted, err := r.IsMounted(ctx, volume)
+		if err != nil {
+			return err
+		}
+		if mounted == mount {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ErrVolumeStateUnchanged
+		}
+		if err := sleepCtx(ctx, replyPollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// IsMounted returns whether the given volume, e.g. 0 for the internal SD card or 1
+// for an external one, is mounted. The state is read from the object model and on
+// firmware without object model inferred by listing the root directory of volume.
+func (r *RRFFileManager) IsMounted(ctx context.Context, volume int) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if volume < 0 {
+		return false, ErrInvalidPath
+	}
+	var v volumeModel
+	err := r.getModel(ctx, fmt.Sprintf("volumes[%d]", volume), "", &v)
+	if err == nil {
+		return v.Mounted, nil
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return r.volumeMounted(ctx, fmt.Sprintf("%d:/", volume))
+}
+
+// DiskSpace returns the free and total number of bytes of the given volume as
+// reported by the object model. It returns ErrDriveNotMounted if the volume is
+// not mounted.
+func (r *RRFFileManager) DiskSpace(ctx context.Context, volume int) (free, total uint64, err error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if volume < 0 {
+		return 0, 0, ErrInvalidPath
+	}
+	var v volumeModel
+	if err := r.getModel(ctx, fmt.Sprintf("volumes[%d]", volume), "", &v); err != nil {
+		return 0, 0, err
+	}
+	if !v.Mounted || v.FreeSpace == nil {
+		return 0, 0, ErrDriveNotMounted
+	}
+	if v.TotalSpace != nil {
+		total = *v.TotalSpace
+	}
+	return *v.FreeSpace, total, nil
+}
+
+// WillFit reports whether totalBytes can be written to the given volume and how
+// many bytes are free on it. Files occupy whole clusters on the card so margin
+// bytes are additionally kept free to account for this rounding.
+func (r *RRFFileManager) WillFit(ctx context.Context, totalBytes uint64, volume int, margin uint64) (fits bool, free uint64, err error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	free, _, err = r.DiskSpace(ctx, volume)
+	if err != nil {
+		return false, 0, err
+	}
+	return free >= margin && free-margin >= totalBytes, free, nil
+}
@@ -0,0 +1,129 @@
+package librfm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// failingBoard reports a failure for every change and describes volume 0 with
+// volume or, if volume is empty, answers like a board without object model
+type failingBoard struct {
+	volume   string
+	mounted  bool
+	files    string
+	requests []string
+}
+
+func (b *failingBoard) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	b.requests = append(b.requests, strings.TrimPrefix(req.URL.Path, "/"))
+	switch req.URL.Path {
+	case "/rr_model":
+		if b.volume == "" {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprintf(w, `{"key":"volumes[0]","flags":"","result":%s}`, b.volume)
+	case "/rr_filelist":
+		if !b.mounted {
+			fmt.Fprint(w, `{"err":1}`)
+			return
+		}
+		fmt.Fprintf(w, `{"dir":%q,"first":0,"files":[%s],"next":0}`, req.URL.Query().Get("dir"), b.files)
+	default:
+		fmt.Fprint(w, `{"err":1}`)
+	}
+}
+
+func TestWriteErrorMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		board    failingBoard
+		want     error
+		requests []string
+	}{
+		{"read-only", failingBoard{volume: `{"mounted":true,"writeSupported":false}`, mounted: true}, ErrReadOnly, []string{"rr_mkdir", "rr_model"}},
+		{"not mounted", failingBoard{volume: `{"mounted":false}`}, ErrDriveNotMounted, []string{"rr_mkdir", "rr_model"}},
+		{"not mounted without object model", failingBoard{}, ErrDriveNotMounted, []string{"rr_mkdir", "rr_model", "rr_filelist"}},
+		{"writable", failingBoard{volume: `{"mounted":true,"writeSupported":true}`, mounted: true}, nil, []string{"rr_mkdir", "rr_model"}},
+		{"writable without object model", failingBoard{mounted: true}, nil, []string{"rr_mkdir", "rr_model", "rr_filelist"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.board
+			r := newTestManager(t, b.ServeHTTP)
+			err := r.Mkdir(context.Background(), "0:/gcodes/new")
+			if tt.want == nil {
+				var rerr *ResponseError
+				if !errors.As(err, &rerr) {
+					t.Errorf("Mkdir = %v, want the board's error", err)
+				}
+			} else if err != tt.want {
+				t.Errorf("Mkdir = %v, want %v", err, tt.want)
+			}
+			if fmt.Sprint(b.requests) != fmt.Sprint(tt.requests) {
+				t.Errorf("requests = %q, want %q", b.requests, tt.requests)
+			}
+			select {
+			case <-r.Done():
+				if tt.want != ErrDriveNotMounted {
+					t.Error("Done() closed for a mounted volume")
+				}
+			default:
+				if tt.want == ErrDriveNotMounted {
+					t.Error("Done() not closed for an unmounted volume")
+				}
+			}
+		})
+	}
+}
+
+func TestUploadRetriesDiagnoseOnce(t *testing.T) {
+	b := &failingBoard{volume: `{"mounted":true,"writeSupported":false}`, mounted: true}
+	r := newTestManager(t, b.ServeHTTP, WithUploadRetries(2))
+
+	_, err := r.Upload(context.Background(), "0:/gcodes/a.g", strings.NewReader("G28"))
+	if !errors.Is(err, ErrUploadRetriesExhausted) || !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Upload = %v, want exhausted retries on a read-only volume", err)
+	}
+	if want := []string{"rr_upload", "rr_upload", "rr_upload", "rr_model"}; fmt.Sprint(b.requests) != fmt.Sprint(want) {
+		t.Errorf("requests = %q, want %q", b.requests, want)
+	}
+}
+
+func TestDeleteIfExistsMissing(t *testing.T) {
+	b := &failingBoard{volume: `{"mounted":true}`, mounted: true, files: `{"type":"f","name":"other.g","size":1,"date":"2024-01-01T00:00:00"}`}
+	r := newTestManager(t, b.ServeHTTP)
+
+	if err := r.DeleteIfExists(context.Background(), "0:/gcodes/a.g"); err != nil {
+		t.Errorf("DeleteIfExists = %v", err)
+	}
+	if want := []string{"rr_delete", "rr_filelist"}; fmt.Sprint(b.requests) != fmt.Sprint(want) {
+		t.Errorf("requests = %q, want %q", b.requests, want)
+	}
+
+	b.mounted, b.requests = false, nil
+	if err := r.DeleteIfExists(context.Background(), "0:/gcodes/a.g"); err != ErrDriveNotMounted {
+		t.Errorf("DeleteIfExists on unmounted volume = %v, want ErrDriveNotMounted", err)
+	}
+}
+
+func TestStatusErrorsNotDiagnosed(t *testing.T) {
+	var requests []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.URL.Path)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	err := r.Mkdir(context.Background(), "0:/gcodes/new")
+	var serr *StatusError
+	if !errors.As(err, &serr) {
+		t.Errorf("Mkdir = %v, want *StatusError", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("requests = %q, want only the mkdir", requests)
+	}
+}
@@ -0,0 +1,40 @@
+package librfm
+
+import "strings"
+
+// SetWorkingDir sets the directory relative paths passed to the manager's methods are
+// resolved against, e.g. after SetWorkingDir("0:/gcodes") Download(ctx, "a.g") fetches
+// "0:/gcodes/a.g". Paths starting with a volume specifier like "0:" or with a slash
+// are absolute and used as is. An empty dir restores the default of sending relative
+// paths to the board unchanged. dir itself should be absolute.
+func (r *RRFFileManager) SetWorkingDir(dir string) {
+	if strings.TrimSpace(dir) != "" {
+		dir = cleanPath(dir)
+	}
+	r.wdMu.Lock()
+	defer r.wdMu.Unlock()
+	r.workingDir = dir
+}
+
+// WorkingDir returns the directory set with SetWorkingDir
+func (r *RRFFileManager) WorkingDir() string {
+	r.wdMu.Lock()
+	defer r.wdMu.Unlock()
+	return r.workingDir
+}
+
+// resolvePath resolves a relative path p against the working directory. Empty and
+// absolute paths are returned unchanged.
+func (r *RRFFileManager) resolvePath(p string) string {
+	wd := r.WorkingDir()
+	if wd == "" || strings.TrimSpace(p) == "" || isAbs(p) {
+		return p
+	}
+	return JoinPath(wd, p)
+}
+
+// isAbs returns true if p starts with a volume specifier or a slash
+func isAbs(p string) bool {
+	volume, rest := splitVolume(strings.ReplaceAll(p, `\`, "/"))
+	return volume != "" || strings.HasPrefix(rest, "/")
+}
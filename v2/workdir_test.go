@@ -0,0 +1,55 @@
+package librfm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	r := New("duet.local", 80, false)
+	if got := r.resolvePath("a.g"); got != "a.g" {
+		t.Errorf("without working dir resolvePath(%q) = %q", "a.g", got)
+	}
+
+	r.SetWorkingDir("0:/gcodes/")
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"a.g", "0:/gcodes/a.g"},
+		{"sub/a.g", "0:/gcodes/sub/a.g"},
+		{`sub\a.g`, "0:/gcodes/sub/a.g"},
+		{"../sys/config.g", "0:/sys/config.g"},
+		{"0:/macros/x.g", "0:/macros/x.g"},
+		{"1:/a.g", "1:/a.g"},
+		{"/sys/config.g", "/sys/config.g"},
+		{`\sys\config.g`, `\sys\config.g`},
+		{"", ""},
+		{"  ", "  "},
+	}
+	for _, tt := range tests {
+		if got := r.resolvePath(tt.in); got != tt.want {
+			t.Errorf("resolvePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWorkingDirRequests(t *testing.T) {
+	var names []string
+	r := newTestManager(t, func(w http.ResponseWriter, req *http.Request) {
+		names = append(names, req.URL.Query().Get("name"))
+		fmt.Fprint(w, `{"err":0}`)
+	})
+	r.SetWorkingDir("0:/gcodes")
+	ctx := context.Background()
+	for _, p := range []string{"a.g", "0:/macros/x.g", "sub/../b.g", "/sys/c.g"} {
+		if err := r.Delete(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"0:/gcodes/a.g", "0:/macros/x.g", "0:/gcodes/b.g", "/sys/c.g"}; fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Errorf("sent %q, want %q", names, want)
+	}
+}